@@ -0,0 +1,185 @@
+// Package admin provides the endpoints reserved to the administrators of jobd
+package admin
+
+import (
+	"crypto/subtle"
+	"jobd/domain/jobs"
+	"jobd/errors"
+	"jobd/services"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+)
+
+// ADMIN_TOKEN authenticates the administrators, the admin endpoints are
+// disabled when it is not set
+var ADMIN_TOKEN = os.Getenv("ADMIN_TOKEN")
+
+// Priority is the body of a priority change
+type Priority struct {
+	Priority *int `json:"priority" binding:"required"`
+}
+
+// Bulk is the body of a change applied to several jobs
+type Bulk struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+// BulkResult lists the jobs a bulk change was applied to and why it failed for the others
+type BulkResult struct {
+	Updated []string                   `json:"updated"`
+	Failed  map[string]*errors.RestErr `json:"failed"`
+}
+
+// Authorize only lets through the requests bearing the admin token
+func Authorize(c *gin.Context) {
+	if ADMIN_TOKEN == "" {
+		err := errors.NewForbiddenError("admin endpoints are disabled, set ADMIN_TOKEN to enable them")
+		c.AbortWithStatusJSON(err.Status, err)
+		return
+	}
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(ADMIN_TOKEN)) != 1 {
+		err := errors.NewStatusUnauthorized("invalid admin token")
+		c.AbortWithStatusJSON(err.Status, err)
+		return
+	}
+
+	c.Next()
+}
+
+// SetPriority godoc
+// @Summary Change the priority of a job
+// @Description Changes the priority of a job waiting to be executed, the highest priorities are executed first. Requires the admin token as `Authorization: Bearer <token>`
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param priority body Priority true "New priority"
+// @Success 200 {object} jobs.Job "Job reprioritized"
+// @Failure 400 {object} errors.RestErr "Bad request - validation error"
+// @Failure 401 {object} errors.RestErr "Invalid admin token"
+// @Failure 403 {object} errors.RestErr "Admin endpoints disabled"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Failure 409 {object} errors.RestErr "Job not waiting anymore"
+// @Router /api/jobs/{id}/priority [put]
+func SetPriority(c *gin.Context) {
+	var request Priority
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		err := errors.NewBadRequestError("error reading priority from request " + err.Error())
+		c.JSON(err.Status, err)
+		return
+	}
+
+	j := jobs.Job{ID: c.Param("id")}
+	result, errSet := services.SetJobPriority(j, *request.Priority)
+	if errSet != nil {
+		glog.Error(errSet)
+		c.JSON(errSet.Status, errSet)
+		return
+	}
+
+	result.HideInternals()
+	c.JSON(http.StatusOK, result)
+}
+
+// Hold godoc
+// @Summary Hold a job
+// @Description Keeps a job waiting to be executed (`QUEUED`, `SCHEDULED` or `WAITING`) from being dispatched, it is `HELD` until it is released. Requires the admin token as `Authorization: Bearer <token>`
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job "Job held"
+// @Failure 401 {object} errors.RestErr "Invalid admin token"
+// @Failure 403 {object} errors.RestErr "Admin endpoints disabled"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Failure 409 {object} errors.RestErr "Job not waiting anymore or already held"
+// @Router /api/jobs/{id}/hold [post]
+func Hold(c *gin.Context) {
+	change(c, services.HoldJob)
+}
+
+// Release godoc
+// @Summary Release a held job
+// @Description Puts a `HELD` job back in line, it waits again for its parent jobs or its start time if it has any. Requires the admin token as `Authorization: Bearer <token>`
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job "Job released"
+// @Failure 401 {object} errors.RestErr "Invalid admin token"
+// @Failure 403 {object} errors.RestErr "Admin endpoints disabled"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Failure 409 {object} errors.RestErr "Job not held"
+// @Router /api/jobs/{id}/release [post]
+func Release(c *gin.Context) {
+	change(c, services.ReleaseJob)
+}
+
+// HoldBulk godoc
+// @Summary Hold several jobs
+// @Description Holds each of the listed jobs, see `/api/jobs/{id}/hold`; the jobs that could not be held are reported with the reason. Requires the admin token as `Authorization: Bearer <token>`
+// @Accept json
+// @Produce json
+// @Param ids body Bulk true "Job IDs"
+// @Success 200 {object} BulkResult "Jobs held and jobs that could not be"
+// @Failure 400 {object} errors.RestErr "Bad request - validation error"
+// @Failure 401 {object} errors.RestErr "Invalid admin token"
+// @Failure 403 {object} errors.RestErr "Admin endpoints disabled"
+// @Router /api/jobs/hold [post]
+func HoldBulk(c *gin.Context) {
+	changeBulk(c, services.HoldJob)
+}
+
+// ReleaseBulk godoc
+// @Summary Release several held jobs
+// @Description Releases each of the listed jobs, see `/api/jobs/{id}/release`; the jobs that could not be released are reported with the reason. Requires the admin token as `Authorization: Bearer <token>`
+// @Accept json
+// @Produce json
+// @Param ids body Bulk true "Job IDs"
+// @Success 200 {object} BulkResult "Jobs released and jobs that could not be"
+// @Failure 400 {object} errors.RestErr "Bad request - validation error"
+// @Failure 401 {object} errors.RestErr "Invalid admin token"
+// @Failure 403 {object} errors.RestErr "Admin endpoints disabled"
+// @Router /api/jobs/release [post]
+func ReleaseBulk(c *gin.Context) {
+	changeBulk(c, services.ReleaseJob)
+}
+
+// change applies `fn` to the job of the request
+func change(c *gin.Context, fn func(jobs.Job) (*jobs.Job, *errors.RestErr)) {
+	j := jobs.Job{ID: c.Param("id")}
+	result, err := fn(j)
+	if err != nil {
+		glog.Error(err)
+		c.JSON(err.Status, err)
+		return
+	}
+
+	result.HideInternals()
+	c.JSON(http.StatusOK, result)
+}
+
+// changeBulk applies `fn` to each job of the request, one failing does not stop the others
+func changeBulk(c *gin.Context, fn func(jobs.Job) (*jobs.Job, *errors.RestErr)) {
+	var request Bulk
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		err := errors.NewBadRequestError("error reading job ids from request " + err.Error())
+		c.JSON(err.Status, err)
+		return
+	}
+
+	result := BulkResult{Updated: []string{}, Failed: map[string]*errors.RestErr{}}
+	for _, id := range request.IDs {
+		_, errChange := fn(jobs.Job{ID: id})
+		if errChange != nil {
+			result.Failed[id] = errChange
+			continue
+		}
+		result.Updated = append(result.Updated, id)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,219 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"jobd/datasource/db"
+	"jobd/domain/jobs"
+	"jobd/domain/status"
+	"jobd/utils/testutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	_ = db.InitDB()
+	gin.SetMode(gin.TestMode)
+}
+
+func TestAuthorize(t *testing.T) {
+	defer func(token string) { ADMIN_TOKEN = token }(ADMIN_TOKEN)
+
+	router := gin.Default()
+	router.GET("/admin", Authorize, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{name: "disabled", token: "", header: "Bearer ", want: http.StatusForbidden},
+		{name: "missing token", token: "secret", header: "", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "valid token", token: "secret", header: "Bearer secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ADMIN_TOKEN = tt.token
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/admin", nil)
+			req.Header.Set("Authorization", tt.header)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+
+	queued := &jobs.Job{ID: "TestSetPriority-queued", Status: status.Queued}
+	testutil.WriteRecord(t, queued.ID, queued)
+	running := &jobs.Job{ID: "TestSetPriority-running", Status: status.Running}
+	testutil.WriteRecord(t, running.ID, running)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.PUT("/jobs/:id/priority", SetPriority)
+
+	tests := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{name: "queued", id: queued.ID, body: `{"priority": 10}`, want: http.StatusOK},
+		{name: "running", id: running.ID, body: `{"priority": 10}`, want: http.StatusConflict},
+		{name: "missing priority", id: queued.ID, body: `{}`, want: http.StatusBadRequest},
+		{name: "unknown job", id: "TestSetPriority-missing", body: `{"priority": 10}`, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/jobs/"+tt.id+"/priority", bytes.NewBufferString(tt.body))
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	got := &jobs.Job{ID: queued.ID}
+	_ = got.Get()
+	if got.Priority != 10 {
+		t.Errorf("Job priority = %d, want 10", got.Priority)
+	}
+}
+
+func TestHoldRelease(t *testing.T) {
+
+	queued := &jobs.Job{ID: "TestHoldRelease-queued", Status: status.Queued}
+	testutil.WriteRecord(t, queued.ID, queued)
+	running := &jobs.Job{ID: "TestHoldRelease-running", Status: status.Running}
+	testutil.WriteRecord(t, running.ID, running)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.POST("/jobs/:id/hold", Hold)
+	router.POST("/jobs/:id/release", Release)
+
+	tests := []struct {
+		name       string
+		path       string
+		want       int
+		wantStatus string
+	}{
+		{name: "hold", path: "/jobs/" + queued.ID + "/hold", want: http.StatusOK, wantStatus: status.Held},
+		{name: "hold again", path: "/jobs/" + queued.ID + "/hold", want: http.StatusConflict, wantStatus: status.Held},
+		{name: "release", path: "/jobs/" + queued.ID + "/release", want: http.StatusOK, wantStatus: status.Queued},
+		{name: "release again", path: "/jobs/" + queued.ID + "/release", want: http.StatusConflict, wantStatus: status.Queued},
+		{name: "hold running", path: "/jobs/" + running.ID + "/hold", want: http.StatusConflict},
+		{name: "unknown job", path: "/jobs/TestHoldRelease-missing/hold", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+			}
+
+			if tt.wantStatus != "" {
+				got := &jobs.Job{ID: queued.ID}
+				_ = got.Get()
+				if got.Status != tt.wantStatus {
+					t.Errorf("Job status = %v, want %v", got.Status, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestHoldReleaseBulk(t *testing.T) {
+
+	for _, id := range []string{"TestHoldReleaseBulk-1", "TestHoldReleaseBulk-2"} {
+		j := &jobs.Job{ID: id, Status: status.Queued}
+		testutil.WriteRecord(t, j.ID, j)
+	}
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.POST("/jobs/hold", HoldBulk)
+	router.POST("/jobs/release", ReleaseBulk)
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		want        int
+		wantUpdated []string
+		wantFailed  []string
+	}{
+		{
+			name:        "hold",
+			path:        "/jobs/hold",
+			body:        `{"ids": ["TestHoldReleaseBulk-1", "TestHoldReleaseBulk-2", "TestHoldReleaseBulk-missing"]}`,
+			want:        http.StatusOK,
+			wantUpdated: []string{"TestHoldReleaseBulk-1", "TestHoldReleaseBulk-2"},
+			wantFailed:  []string{"TestHoldReleaseBulk-missing"},
+		},
+		{
+			name:        "release",
+			path:        "/jobs/release",
+			body:        `{"ids": ["TestHoldReleaseBulk-1"]}`,
+			want:        http.StatusOK,
+			wantUpdated: []string{"TestHoldReleaseBulk-1"},
+			wantFailed:  []string{},
+		},
+		{
+			name: "missing ids",
+			path: "/jobs/hold",
+			body: `{}`,
+			want: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var got BulkResult
+			_ = json.Unmarshal(w.Body.Bytes(), &got)
+			if !reflect.DeepEqual(got.Updated, tt.wantUpdated) {
+				t.Errorf("Updated = %v, want %v", got.Updated, tt.wantUpdated)
+			}
+			failed := []string{}
+			for id := range got.Failed {
+				failed = append(failed, id)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("Failed = %v, want %v", failed, tt.wantFailed)
+			}
+		})
+	}
+
+	got := &jobs.Job{ID: "TestHoldReleaseBulk-2"}
+	_ = got.Get()
+	if got.Status != status.Held {
+		t.Errorf("Job status = %v, want %v", got.Status, status.Held)
+	}
+}
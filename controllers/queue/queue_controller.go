@@ -7,20 +7,24 @@ import (
 	"jobd/errors"
 	"jobd/services"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/glog"
 )
 
 // UploadJob godoc
 // @Summary Upload a new job to the queue
-// @Description Upload a payload. `id` is a unique user-provided job identificator. The `input` field must contain a base64 encoded`.zip` file with a `run.sh` script and the input data. `slurml` marks the job for redirection to the `slurml` endpoint (wip)
+// @Description Upload a job, it is queued until a worker executes it. The fields of the upload are described in `jobs.Upload`, only `id` and `input` (or `input_from`) are required
 // @Accept json
 // @Produce json
 // @Param job body jobs.Upload true "Job to be uploaded"
 // @Success 201 {object} jobs.Job "Job successfully created"
 // @Failure 400 {object} errors.RestErr "Bad request - validation error"
 // @Failure 500 {object} errors.RestErr "Internal server error"
+// @Failure 503 {object} errors.RestErr "Shutting down - not accepting jobs"
 // @Router /api/upload [post]
 func UploadJob(c *gin.Context) {
 	var j jobs.Job
@@ -34,9 +38,20 @@ func UploadJob(c *gin.Context) {
 	}
 
 	j = jobs.Job{
-		ID:     uploadRequest.Id,
-		Input:  uploadRequest.Input,
-		Slurml: uploadRequest.Slurml,
+		ID:              uploadRequest.Id,
+		Input:           uploadRequest.Input,
+		Slurml:          uploadRequest.Slurml,
+		Timeout:         uploadRequest.Timeout,
+		Limits:          uploadRequest.Limits,
+		Env:             uploadRequest.Env,
+		Retry:           uploadRequest.Retry,
+		Priority:        uploadRequest.Priority,
+		NotBefore:       uploadRequest.NotBefore,
+		DependsOn:       uploadRequest.DependsOn,
+		InputFrom:       uploadRequest.InputFrom,
+		StartHeld:       uploadRequest.Hold,
+		Array:           uploadRequest.Array,
+		OutputSelection: uploadRequest.OutputSelection,
 	}
 
 	if err := j.Validate(); err != nil {
@@ -58,7 +73,7 @@ func UploadJob(c *gin.Context) {
 
 // RetrieveJob godoc
 // @Summary Retrieve a job from the queue
-// @Description Fetches a job by its `id` (provided by the user) with partial content handling
+// @Description Fetches a job by its `id` (provided by the user) with partial content handling: running jobs that published results answer `206` with a snapshot of them as the output
 // @Produce json
 // @Param id path string true "Job ID"
 // @Success 200 {object} jobs.Job "Successfully retrieved job"
@@ -78,8 +93,7 @@ func RetrieveJob(c *gin.Context) {
 
 	// Do things related to getting the job?
 	// Clear the input and path before returning the job
-	result.Input = ""
-	result.Path = ""
+	result.HideInternals()
 
 	switch result.Status {
 	case status.Partial:
@@ -88,3 +102,231 @@ func RetrieveJob(c *gin.Context) {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// RetrieveOutput godoc
+// @Summary Download the output of a job
+// @Description Streams the output of a finished job as a `.zip` file, without the base64 and JSON overhead of `/api/get/{id}`. Supports `Range` requests, with `If-Range` on the `ETag` (the SHA-256 of the file) to resume a download. Running jobs that published results get a snapshot of them, `X-Job-Status` tells the status of the job
+// @Produce application/zip
+// @Param id path string true "Job ID"
+// @Param Range header string false "Byte range to download"
+// @Success 200 {file} file "Job output"
+// @Success 206 {file} file "Requested range of the job output"
+// @Failure 202 {object} errors.RestErr "Job not finished"
+// @Failure 400 {object} errors.RestErr "Invalid job id"
+// @Failure 404 {object} errors.RestErr "Job or output not found"
+// @Failure 416 {string} string "Range not satisfiable"
+// @Router /api/jobs/{id}/output [get]
+func RetrieveOutput(c *gin.Context) {
+	j := jobs.Job{ID: c.Param("id")}
+
+	archive, err := services.GetJobOutput(j)
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+	defer archive.Close()
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+archive.ID+`.zip"`)
+	c.Header("ETag", `"`+archive.SHA256+`"`)
+	c.Header("X-Job-Status", archive.Status)
+	http.ServeContent(c.Writer, c.Request, archive.ID+".zip", archive.Modified, archive)
+}
+
+// RetrieveStatus godoc
+// @Summary Show the state of a job
+// @Description Returns a job whatever its state, without its output. Scheduled jobs have the status `SCHEDULED` and their start time in `NotBefore`, running jobs the progress they reported in `Progress`
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job "Job state"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Router /api/jobs/{id} [get]
+func RetrieveStatus(c *gin.Context) {
+	j := jobs.Job{ID: c.Param("id")}
+
+	result, err := services.GetJobStatus(j)
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	result.HideInternals()
+	c.JSON(http.StatusOK, result)
+}
+
+// RetrieveGraph godoc
+// @Summary Show the dependency graph of a job
+// @Description Returns the jobs a job depends on, directly or not, the ones depending on it, and the status of each. Edges go from a parent to the job depending on it
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Graph "Dependency graph"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Router /api/jobs/{id}/graph [get]
+func RetrieveGraph(c *gin.Context) {
+	j := jobs.Job{ID: c.Param("id")}
+
+	result, err := services.GetJobGraph(j)
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CancelJob godoc
+// @Summary Cancel a job
+// @Description Stops a job whatever its state. Queued or held jobs are not executed, running jobs have their process tree terminated and `slurml` jobs are cancelled remotely. The job ends as `CANCELLED` keeping any partial output
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job "Job cancelled"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Failure 409 {object} errors.RestErr "Job already finished"
+// @Failure 500 {object} errors.RestErr "Internal server error"
+// @Router /api/jobs/{id} [delete]
+// @Router /api/jobs/{id}/cancel [post]
+func CancelJob(c *gin.Context) {
+	j := jobs.Job{ID: c.Param("id")}
+
+	result, err := services.CancelJob(j)
+	if err != nil {
+		glog.Error(err)
+		c.JSON(err.Status, err)
+		return
+	}
+
+	result.HideInternals()
+	result.Output = ""
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetQueueStats godoc
+// @Summary Show the state of the queue
+// @Description Returns the size of the local worker pool, how many local jobs are running and how many jobs are queued, scheduled, waiting or held
+// @Produce json
+// @Success 200 {object} queue.Stats "Queue statistics"
+// @Router /api/queue [get]
+func GetQueueStats(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetQueueStats())
+}
+
+// RetrieveStdout godoc
+// @Summary Retrieve the stdout of a job
+// @Description Returns the standard output written by the job so far, logs are kept after the job finishes
+// @Produce plain
+// @Param id path string true "Job ID"
+// @Success 200 {string} string "Job stdout"
+// @Failure 404 {object} errors.RestErr "Job or log not found"
+// @Router /api/jobs/{id}/stdout [get]
+func RetrieveStdout(c *gin.Context) {
+	retrieveLog(c, "stdout")
+}
+
+// RetrieveStderr godoc
+// @Summary Retrieve the stderr of a job
+// @Description Returns the standard error written by the job so far, logs are kept after the job finishes
+// @Produce plain
+// @Param id path string true "Job ID"
+// @Success 200 {string} string "Job stderr"
+// @Failure 404 {object} errors.RestErr "Job or log not found"
+// @Router /api/jobs/{id}/stderr [get]
+func RetrieveStderr(c *gin.Context) {
+	retrieveLog(c, "stderr")
+}
+
+// StreamLogs godoc
+// @Summary Stream the logs of a job
+// @Description Streams the stdout and stderr of a job as Server-Sent Events (`stdout` and `stderr` events), and its progress as JSON `progress` events, while it runs, then sends an `end` event with the final status and closes. The `id` of each event holds the offsets `<stdout>:<stderr>`; to resume after a reconnect send it back as `Last-Event-ID` or use `stdout_offset`/`stderr_offset`
+// @Produce text/event-stream
+// @Param id path string true "Job ID"
+// @Param stdout_offset query int false "Byte offset of stdout to start from"
+// @Param stderr_offset query int false "Byte offset of stderr to start from"
+// @Param Last-Event-ID header string false "Id of the last event received"
+// @Success 200 {string} string "Log events"
+// @Failure 400 {object} errors.RestErr "Invalid offsets"
+// @Failure 404 {object} errors.RestErr "Job not found"
+// @Router /api/jobs/{id}/logs/stream [get]
+func StreamLogs(c *gin.Context) {
+	j := jobs.Job{ID: c.Param("id")}
+
+	offsets, errOffsets := logOffsets(c)
+	if errOffsets != nil {
+		c.JSON(errOffsets.Status, errOffsets)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	last, err := services.StreamJobLogs(ctx, j, offsets, func(chunk services.LogChunk) bool {
+		c.Render(-1, sse.Event{
+			Id:    eventID(chunk.Offsets),
+			Event: chunk.Stream,
+			Data:  string(chunk.Data),
+		})
+		c.Writer.Flush()
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	if ctx.Err() == nil {
+		c.Render(-1, sse.Event{Event: "end", Data: last})
+		c.Writer.Flush()
+	}
+}
+
+// eventID encodes the log offsets in the id of an event
+func eventID(o services.LogOffsets) string {
+	return strconv.FormatInt(o.Stdout, 10) + ":" + strconv.FormatInt(o.Stderr, 10)
+}
+
+// logOffsets reads where a log stream resumes from, the query has precedence
+// over the Last-Event-ID header
+func logOffsets(c *gin.Context) (services.LogOffsets, *errors.RestErr) {
+	offsets := services.LogOffsets{}
+	invalid := errors.NewBadRequestError("offsets must be positive integers")
+
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		stdout, stderr, _ := strings.Cut(id, ":")
+		var err error
+		if offsets.Stdout, err = strconv.ParseInt(stdout, 10, 64); err != nil {
+			return offsets, invalid
+		}
+		if offsets.Stderr, err = strconv.ParseInt(stderr, 10, 64); err != nil {
+			return offsets, invalid
+		}
+	}
+
+	for name, offset := range map[string]*int64{"stdout_offset": &offsets.Stdout, "stderr_offset": &offsets.Stderr} {
+		if v, ok := c.GetQuery(name); ok {
+			var err error
+			if *offset, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return offsets, invalid
+			}
+		}
+	}
+
+	if offsets.Stdout < 0 || offsets.Stderr < 0 {
+		return offsets, invalid
+	}
+
+	return offsets, nil
+}
+
+func retrieveLog(c *gin.Context, stream string) {
+	j := jobs.Job{ID: c.Param("id")}
+
+	path, err := services.GetJobLog(j, stream)
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.File(path)
+}
@@ -2,20 +2,36 @@ package queue
 
 import (
 	"bytes"
+	"encoding/base64"
 	"jobd/datasource/db"
 	"jobd/domain/jobs"
+	"jobd/domain/status"
+	"jobd/services"
+	"jobd/utils/testutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 )
 
-func init() {
+// TestMain keeps the database, the job directories and the logs of the tests in
+// a temporary directory removed once they are done
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "jobd-queue-test-")
+	if err != nil {
+		panic(err)
+	}
+	services.DATAPATH, services.LOGPATH, db.NAME = dir+"/data", dir+"/logs", dir+"/db"
 	_ = db.InitDB()
 	// _ = migrations.Migrate()
 	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
 }
 
 func TestUploadJob(t *testing.T) {
@@ -181,3 +197,249 @@ func TestRetrieveJob(t *testing.T) {
 	}
 
 }
+
+func TestRetrieveOutput(t *testing.T) {
+
+	output := []byte("PK zipped output of the job")
+	j := &jobs.Job{ID: "TestRetrieveOutput", Status: status.Success, Output: base64.StdEncoding.EncodeToString(output)}
+	testutil.WriteRecord(t, j.ID, j)
+	running := &jobs.Job{ID: "TestRetrieveOutputRunning", Status: status.Running}
+	testutil.WriteRecord(t, running.ID, running)
+	testutil.CleanupDB(t)
+	defer os.RemoveAll(services.LOGPATH + "/" + j.ID)
+
+	router := gin.Default()
+	router.GET("/jobs/:id/output", RetrieveOutput)
+
+	get := func(id string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/jobs/"+id+"/output", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The whole output
+	w := get(j.ID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), output) {
+		t.Errorf("Expected body %q, got %q", output, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/zip" {
+		t.Errorf("Expected Content-Type application/zip, got %v", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(output)) {
+		t.Errorf("Expected Content-Length %d, got %v", len(output), got)
+	}
+	etag := w.Header().Get("ETag")
+
+	// Resuming the download
+	w = get(j.ID, map[string]string{"Range": "bytes=3-", "If-Range": etag})
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusPartialContent, w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), output[3:]) {
+		t.Errorf("Expected body %q, got %q", output[3:], w.Body.String())
+	}
+
+	// The output changed since the download started
+	w = get(j.ID, map[string]string{"Range": "bytes=3-", "If-Range": `"other"`})
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), output) {
+		t.Errorf("Expected the whole output with status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	w = get(j.ID, map[string]string{"Range": "bytes=1000-"})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected status code %d, got %d", http.StatusRequestedRangeNotSatisfiable, w.Code)
+	}
+
+	w = get(running.ID, nil)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	w = get("does-not-exist", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRetrieveStdout(t *testing.T) {
+
+	logDir := "./test-retrieve-stdout"
+	_ = os.MkdirAll(logDir, 0755)
+	_ = os.WriteFile(logDir+"/"+jobs.StdoutLog, []byte("hello"), 0644)
+	defer os.RemoveAll(logDir)
+
+	j := &jobs.Job{ID: "TestRetrieveStdout", LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.GET("/jobs/:id/stdout", RetrieveStdout)
+	router.GET("/jobs/:id/stderr", RetrieveStderr)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/jobs/"+j.ID+"/stdout", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.String() != "hello" {
+		t.Errorf("Expected body %q, got %q", "hello", w.Body.String())
+	}
+
+	// stderr was never written
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/jobs/"+j.ID+"/stderr", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+
+	logDir := "./test-stream-logs"
+	_ = os.MkdirAll(logDir, 0755)
+	_ = os.WriteFile(logDir+"/"+jobs.StdoutLog, []byte("hello\nworld\n"), 0644)
+	defer os.RemoveAll(logDir)
+
+	j := &jobs.Job{ID: "TestStreamLogs", Status: status.Success, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.GET("/jobs/:id/logs/stream", StreamLogs)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/jobs/"+j.ID+"/logs/stream", nil)
+	router.ServeHTTP(w, req)
+
+	want := "id:12:0\nevent:stdout\ndata:hello\ndata:world\ndata:\n\nevent:end\ndata:SUCCESS\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected body %q, got %q", want, w.Body.String())
+	}
+
+	// Resuming from the last event
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/jobs/"+j.ID+"/logs/stream", nil)
+	req.Header.Set("Last-Event-ID", "6:0")
+	router.ServeHTTP(w, req)
+
+	want = "id:12:0\nevent:stdout\ndata:world\ndata:\n\nevent:end\ndata:SUCCESS\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected body %q, got %q", want, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/jobs/"+j.ID+"/logs/stream?stdout_offset=-1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRetrieveStatus(t *testing.T) {
+
+	j := &jobs.Job{ID: "TestRetrieveStatus", Status: status.Scheduled, Input: "input", Output: "output"}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.GET("/jobs/:id", RetrieveStatus)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/jobs/"+j.ID, nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"Status":"SCHEDULED"`)) {
+		t.Errorf("Expected the status in %s", w.Body.String())
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("output")) || bytes.Contains(w.Body.Bytes(), []byte(`"input"`)) {
+		t.Errorf("Expected no input or output in %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/jobs/TestRetrieveStatus-missing", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRetrieveGraph(t *testing.T) {
+
+	parent := &jobs.Job{ID: "TestRetrieveGraph-parent", Status: status.Success}
+	testutil.WriteRecord(t, parent.ID, parent)
+	child := &jobs.Job{ID: "TestRetrieveGraph-child", Status: status.Waiting, DependsOn: []string{parent.ID}}
+	testutil.WriteRecord(t, child.ID, child)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.GET("/jobs/:id/graph", RetrieveGraph)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/jobs/"+parent.ID+"/graph", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	want := `{"nodes":[{"id":"TestRetrieveGraph-child","status":"WAITING"},{"id":"TestRetrieveGraph-parent","status":"SUCCESS"}],"edges":[{"from":"TestRetrieveGraph-parent","to":"TestRetrieveGraph-child"}]}`
+	if w.Body.String() != want {
+		t.Errorf("Expected body %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+
+	j := &jobs.Job{ID: "TestCancelJob", Status: status.Queued}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.CleanupDB(t)
+
+	router := gin.Default()
+	router.DELETE("/jobs/:id", CancelJob)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/jobs/"+j.ID, nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Cancelling it again conflicts
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("DELETE", "/jobs/"+j.ID, nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestGetQueueStats(t *testing.T) {
+
+	router := gin.Default()
+	router.GET("/queue", GetQueueStats)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/queue", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+}
@@ -2,6 +2,7 @@
 package router
 
 import (
+	admin "jobd/controllers/admin"
 	queue "jobd/controllers/queue"
 
 	// Import your local docs package
@@ -18,6 +19,20 @@ func SetupRouter() *gin.Engine {
 	r := gin.Default()
 	r.POST("/api/upload", queue.UploadJob)
 	r.GET("/api/get/:id", queue.RetrieveJob)
+	r.GET("/api/queue", queue.GetQueueStats)
+	r.GET("/api/jobs/:id", queue.RetrieveStatus)
+	r.DELETE("/api/jobs/:id", queue.CancelJob)
+	r.POST("/api/jobs/:id/cancel", queue.CancelJob)
+	r.GET("/api/jobs/:id/stdout", queue.RetrieveStdout)
+	r.GET("/api/jobs/:id/stderr", queue.RetrieveStderr)
+	r.GET("/api/jobs/:id/output", queue.RetrieveOutput)
+	r.GET("/api/jobs/:id/logs/stream", queue.StreamLogs)
+	r.GET("/api/jobs/:id/graph", queue.RetrieveGraph)
+	r.PUT("/api/jobs/:id/priority", admin.Authorize, admin.SetPriority)
+	r.POST("/api/jobs/:id/hold", admin.Authorize, admin.Hold)
+	r.POST("/api/jobs/:id/release", admin.Authorize, admin.Release)
+	r.POST("/api/jobs/hold", admin.Authorize, admin.HoldBulk)
+	r.POST("/api/jobs/release", admin.Authorize, admin.ReleaseBulk)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	return r
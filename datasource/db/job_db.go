@@ -2,6 +2,8 @@ package db
 
 import (
 	"os"
+	"path/filepath"
+	"syscall"
 
 	"github.com/golang/glog"
 	scribble "github.com/nanobox-io/golang-scribble"
@@ -25,3 +27,35 @@ func InitDB() error {
 	Client = database
 	return nil
 }
+
+// Lock takes an exclusive lock on a resource of the database, the lock is held
+// on a file so it is shared with any other process using the same database;
+// the returned function releases it
+func Lock(resource string) (func(), error) {
+	dir := filepath.Join(NAME, ".locks")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, resource+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// RemoveLock deletes the lock file of a resource that no longer exists
+func RemoveLock(resource string) {
+	_ = os.Remove(filepath.Join(NAME, ".locks", resource+".lock"))
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestInitDB(t *testing.T) {
@@ -26,3 +27,39 @@ func TestInitDB(t *testing.T) {
 		})
 	}
 }
+
+func TestLock(t *testing.T) {
+	// Delete the database after the test
+	defer os.RemoveAll(NAME)
+
+	unlock, err := Lock("TestLock")
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := Lock("TestLock")
+		if err != nil {
+			t.Errorf("Lock() error = %v", err)
+			close(acquired)
+			return
+		}
+		close(acquired)
+		unlock2()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("Lock() acquired a lock that is already held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Lock() was not acquired after it was released")
+	}
+}
@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/api/get/{id}": {
             "get": {
-                "description": "Fetches a job by its ` + "`" + `id` + "`" + ` (provided by the user) with partial content handling",
+                "description": "Fetches a job by its ` + "`" + `id` + "`" + ` (provided by the user) with partial content handling: running jobs that published results answer ` + "`" + `206` + "`" + ` with a snapshot of them as the output",
                 "produces": [
                     "application/json"
                 ],
@@ -59,9 +59,624 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/jobs/hold": {
+            "post": {
+                "description": "Holds each of the listed jobs, see ` + "`" + `/api/jobs/{id}/hold` + "`" + `; the jobs that could not be held are reported with the reason. Requires the admin token as ` + "`" + `Authorization: Bearer \u003ctoken\u003e` + "`" + `",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Hold several jobs",
+                "parameters": [
+                    {
+                        "description": "Job IDs",
+                        "name": "ids",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.Bulk"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Jobs held and jobs that could not be",
+                        "schema": {
+                            "$ref": "#/definitions/admin.BulkResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin endpoints disabled",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/release": {
+            "post": {
+                "description": "Releases each of the listed jobs, see ` + "`" + `/api/jobs/{id}/release` + "`" + `; the jobs that could not be released are reported with the reason. Requires the admin token as ` + "`" + `Authorization: Bearer \u003ctoken\u003e` + "`" + `",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Release several held jobs",
+                "parameters": [
+                    {
+                        "description": "Job IDs",
+                        "name": "ids",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.Bulk"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Jobs released and jobs that could not be",
+                        "schema": {
+                            "$ref": "#/definitions/admin.BulkResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin endpoints disabled",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}": {
+            "get": {
+                "description": "Returns a job whatever its state, without its output. Scheduled jobs have the status ` + "`" + `SCHEDULED` + "`" + ` and their start time in ` + "`" + `NotBefore` + "`" + `, running jobs the progress they reported in ` + "`" + `Progress` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Show the state of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job state",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops a job whatever its state. Queued or held jobs are not executed, running jobs have their process tree terminated and ` + "`" + `slurml` + "`" + ` jobs are cancelled remotely. The job ends as ` + "`" + `CANCELLED` + "`" + ` keeping any partial output",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancel a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "409": {
+                        "description": "Job already finished",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/cancel": {
+            "post": {
+                "description": "Stops a job whatever its state. Queued or held jobs are not executed, running jobs have their process tree terminated and ` + "`" + `slurml` + "`" + ` jobs are cancelled remotely. The job ends as ` + "`" + `CANCELLED` + "`" + ` keeping any partial output",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Cancel a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "409": {
+                        "description": "Job already finished",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/graph": {
+            "get": {
+                "description": "Returns the jobs a job depends on, directly or not, the ones depending on it, and the status of each. Edges go from a parent to the job depending on it",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Show the dependency graph of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dependency graph",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Graph"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/hold": {
+            "post": {
+                "description": "Keeps a job waiting to be executed (` + "`" + `QUEUED` + "`" + `, ` + "`" + `SCHEDULED` + "`" + ` or ` + "`" + `WAITING` + "`" + `) from being dispatched, it is ` + "`" + `HELD` + "`" + ` until it is released. Requires the admin token as ` + "`" + `Authorization: Bearer \u003ctoken\u003e` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Hold a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job held",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin endpoints disabled",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "409": {
+                        "description": "Job not waiting anymore or already held",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/logs/stream": {
+            "get": {
+                "description": "Streams the stdout and stderr of a job as Server-Sent Events (` + "`" + `stdout` + "`" + ` and ` + "`" + `stderr` + "`" + ` events), and its progress as JSON ` + "`" + `progress` + "`" + ` events, while it runs, then sends an ` + "`" + `end` + "`" + ` event with the final status and closes. The ` + "`" + `id` + "`" + ` of each event holds the offsets ` + "`" + `\u003cstdout\u003e:\u003cstderr\u003e` + "`" + `; to resume after a reconnect send it back as ` + "`" + `Last-Event-ID` + "`" + ` or use ` + "`" + `stdout_offset` + "`" + `/` + "`" + `stderr_offset` + "`" + `",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream the logs of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Byte offset of stdout to start from",
+                        "name": "stdout_offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Byte offset of stderr to start from",
+                        "name": "stderr_offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Log events",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid offsets",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/output": {
+            "get": {
+                "description": "Streams the output of a finished job as a ` + "`" + `.zip` + "`" + ` file, without the base64 and JSON overhead of ` + "`" + `/api/get/{id}` + "`" + `. Supports ` + "`" + `Range` + "`" + ` requests, with ` + "`" + `If-Range` + "`" + ` on the ` + "`" + `ETag` + "`" + ` (the SHA-256 of the file) to resume a download. Running jobs that published results get a snapshot of them, ` + "`" + `X-Job-Status` + "`" + ` tells the status of the job",
+                "produces": [
+                    "application/zip"
+                ],
+                "summary": "Download the output of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range to download",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job output",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "202": {
+                        "description": "Job not finished",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested range of the job output",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid job id",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "404": {
+                        "description": "Job or output not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/priority": {
+            "put": {
+                "description": "Changes the priority of a job waiting to be executed, the highest priorities are executed first. Requires the admin token as ` + "`" + `Authorization: Bearer \u003ctoken\u003e` + "`" + `",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Change the priority of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New priority",
+                        "name": "priority",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/admin.Priority"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job reprioritized",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - validation error",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin endpoints disabled",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "409": {
+                        "description": "Job not waiting anymore",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/release": {
+            "post": {
+                "description": "Puts a ` + "`" + `HELD` + "`" + ` job back in line, it waits again for its parent jobs or its start time if it has any. Requires the admin token as ` + "`" + `Authorization: Bearer \u003ctoken\u003e` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Release a held job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job released",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin endpoints disabled",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    },
+                    "409": {
+                        "description": "Job not held",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/stderr": {
+            "get": {
+                "description": "Returns the standard error written by the job so far, logs are kept after the job finishes",
+                "produces": [
+                    "text/plain"
+                ],
+                "summary": "Retrieve the stderr of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job stderr",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Job or log not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/stdout": {
+            "get": {
+                "description": "Returns the standard output written by the job so far, logs are kept after the job finishes",
+                "produces": [
+                    "text/plain"
+                ],
+                "summary": "Retrieve the stdout of a job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job stdout",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Job or log not found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/queue": {
+            "get": {
+                "description": "Returns the size of the local worker pool, how many local jobs are running and how many jobs are queued, scheduled, waiting or held",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Show the state of the queue",
+                "responses": {
+                    "200": {
+                        "description": "Queue statistics",
+                        "schema": {
+                            "$ref": "#/definitions/queue.Stats"
+                        }
+                    }
+                }
+            }
+        },
         "/api/upload": {
             "post": {
-                "description": "Upload a payload. ` + "`" + `id` + "`" + ` is a unique user-provided job identificator. The ` + "`" + `input` + "`" + ` field must contain a base64 encoded` + "`" + `.zip` + "`" + ` file with a ` + "`" + `run.sh` + "`" + ` script and the input data. ` + "`" + `slurml` + "`" + ` marks the job for redirection to the ` + "`" + `slurml` + "`" + ` endpoint (wip)",
+                "description": "Upload a job, it is queued until a worker executes it. The fields of the upload are described in ` + "`" + `jobs.Upload` + "`" + `, only ` + "`" + `id` + "`" + ` and ` + "`" + `input` + "`" + ` (or ` + "`" + `input_from` + "`" + `) are required",
                 "consumes": [
                     "application/json"
                 ],
@@ -98,12 +713,60 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/errors.RestErr"
                         }
+                    },
+                    "503": {
+                        "description": "Shutting down - not accepting jobs",
+                        "schema": {
+                            "$ref": "#/definitions/errors.RestErr"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "admin.Bulk": {
+            "type": "object",
+            "required": [
+                "ids"
+            ],
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "admin.BulkResult": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/errors.RestErr"
+                    }
+                },
+                "updated": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "admin.Priority": {
+            "type": "object",
+            "required": [
+                "priority"
+            ],
+            "properties": {
+                "priority": {
+                    "type": "integer"
+                }
+            }
+        },
         "errors.RestErr": {
             "type": "object",
             "properties": {
@@ -118,49 +781,476 @@ const docTemplate = `{
                 }
             }
         },
+        "jobs.Array": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "description": "Children are the ids of the child jobs, Counts how many of them are in\neach status",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "jobs.Attempt": {
+            "type": "object",
+            "properties": {
+                "failure": {
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "integer"
+                },
+                "process": {
+                    "$ref": "#/definitions/utils.ProcessInfo"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "jobs.Edge": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "jobs.Graph": {
+            "type": "object",
+            "properties": {
+                "edges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jobs.Edge"
+                    }
+                },
+                "nodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jobs.Node"
+                    }
+                }
+            }
+        },
         "jobs.Job": {
             "type": "object",
             "properties": {
+                "array": {
+                    "$ref": "#/definitions/jobs.Array"
+                },
+                "arrayID": {
+                    "type": "string"
+                },
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jobs.Attempt"
+                    }
+                },
+                "created": {
+                    "type": "string"
+                },
+                "dependsOn": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "env": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "id": {
                     "type": "string"
                 },
                 "input": {
                     "type": "string"
                 },
+                "inputFrom": {
+                    "type": "string"
+                },
                 "lastUpdated": {
                     "type": "string"
                 },
+                "limits": {
+                    "$ref": "#/definitions/utils.Limits"
+                },
+                "logPath": {
+                    "type": "string"
+                },
+                "manifest": {
+                    "$ref": "#/definitions/jobs.Manifest"
+                },
                 "message": {
                     "type": "string"
                 },
+                "notBefore": {
+                    "type": "string"
+                },
                 "output": {
                     "type": "string"
                 },
+                "outputManifest": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jobs.OutputFile"
+                    }
+                },
+                "outputSelection": {
+                    "$ref": "#/definitions/jobs.OutputSelection"
+                },
+                "owner": {
+                    "type": "string"
+                },
                 "path": {
                     "type": "string"
                 },
+                "postProcess": {
+                    "$ref": "#/definitions/utils.ProcessInfo"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "process": {
+                    "$ref": "#/definitions/utils.ProcessInfo"
+                },
+                "progress": {
+                    "$ref": "#/definitions/jobs.Progress"
+                },
+                "retry": {
+                    "$ref": "#/definitions/jobs.RetryPolicy"
+                },
                 "slurmID": {
                     "type": "integer"
                 },
                 "slurml": {
                     "type": "boolean"
                 },
+                "startHeld": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timeout": {
+                    "type": "integer"
+                }
+            }
+        },
+        "jobs.Manifest": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "description": "Args are given to the command",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "command": {
+                    "description": "Command is the program to run, relative to the job directory or in the PATH",
+                    "type": "string"
+                },
+                "env": {
+                    "description": "Env are extra environment variables of the job",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "interpreter": {
+                    "description": "Interpreter runs the command, e.g. ` + "`" + `python3` + "`" + `",
+                    "type": "string"
+                },
+                "outputs": {
+                    "description": "Outputs are glob patterns of the files the job must produce",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "timeout": {
+                    "description": "Timeout is the wall-clock limit of the job in seconds, used when the upload has none",
+                    "type": "integer"
+                }
+            }
+        },
+        "jobs.Node": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
                 "status": {
                     "type": "string"
                 }
             }
         },
+        "jobs.OutputFile": {
+            "type": "object",
+            "properties": {
+                "modified": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "jobs.OutputSelection": {
+            "type": "object",
+            "properties": {
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "exclude_inputs": {
+                    "type": "boolean"
+                },
+                "include": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "jobs.Progress": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "percent": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "string"
+                }
+            }
+        },
+        "jobs.RetryPolicy": {
+            "type": "object",
+            "properties": {
+                "backoff": {
+                    "description": "Backoff is how long to wait, in seconds, before the first retry; it doubles\non each one and 0 retries right away",
+                    "type": "integer"
+                },
+                "max_attempts": {
+                    "description": "MaxAttempts is the number of times the job is executed at most, 1 means no retries",
+                    "type": "integer"
+                },
+                "on": {
+                    "description": "On are the failure classes that are retried: exit, timeout, error and post",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "jobs.Upload": {
             "type": "object",
             "properties": {
+                "array": {
+                    "description": "Array expands the upload into child jobs, one per index of a range or per\nparameter set",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/jobs.Array"
+                        }
+                    ]
+                },
+                "depends_on": {
+                    "description": "DependsOn are the jobs that must succeed before this one starts",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "env": {
+                    "description": "Env are extra environment variables of the job",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "hold": {
+                    "description": "Hold submits the job as HELD, it is not executed until an admin releases it",
+                    "type": "boolean"
+                },
                 "id": {
+                    "description": "Id is a unique job identifier chosen by the client",
                     "type": "string"
                 },
                 "input": {
+                    "description": "Input is a base64 encoded ` + "`" + `.zip` + "`" + ` file with a ` + "`" + `run.sh` + "`" + ` script, or a\n` + "`" + `jobd.json` + "`" + `/` + "`" + `jobd.yaml` + "`" + ` manifest describing the command, the input data\nand optionally a ` + "`" + `post.sh` + "`" + ` post-processing the results once the job succeeded",
+                    "type": "string"
+                },
+                "input_from": {
+                    "description": "InputFrom is a job whose output is the input of this one, ` + "`" + `input` + "`" + ` is then\nan optional overlay",
+                    "type": "string"
+                },
+                "limits": {
+                    "description": "Limits are the resources the job can use, capped by the server",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/utils.Limits"
+                        }
+                    ]
+                },
+                "not_before": {
+                    "description": "NotBefore is the earliest time the job can start",
                     "type": "string"
                 },
+                "output_selection": {
+                    "description": "OutputSelection chooses the files that go in the output: its ` + "`" + `include` + "`" + `\npatterns replace the server ones, its ` + "`" + `exclude` + "`" + ` patterns are added to them",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/jobs.OutputSelection"
+                        }
+                    ]
+                },
+                "priority": {
+                    "description": "Priority of the job, the highest are executed first; capped by the server,\nonly admins raise it by default",
+                    "type": "integer"
+                },
+                "retry": {
+                    "description": "Retry says if and when the job is executed again after a failure",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/jobs.RetryPolicy"
+                        }
+                    ]
+                },
                 "slurml": {
+                    "description": "Slurml redirects the job to the ` + "`" + `slurml` + "`" + ` endpoint (wip)",
                     "type": "boolean"
+                },
+                "timeout": {
+                    "description": "Timeout is the wall-clock limit of the job in seconds",
+                    "type": "integer"
+                }
+            }
+        },
+        "queue.Stats": {
+            "type": "object",
+            "properties": {
+                "held": {
+                    "description": "Held is the number of jobs held until an admin releases them",
+                    "type": "integer"
+                },
+                "queued": {
+                    "description": "Queued is the number of jobs waiting to be executed",
+                    "type": "integer"
+                },
+                "running": {
+                    "description": "Running is the number of local jobs being executed",
+                    "type": "integer"
+                },
+                "scheduled": {
+                    "description": "Scheduled is the number of jobs waiting for their start time",
+                    "type": "integer"
+                },
+                "waiting": {
+                    "description": "Waiting is the number of jobs waiting for their parent jobs",
+                    "type": "integer"
+                },
+                "workers": {
+                    "description": "Workers is the maximum number of local jobs executed at the same time",
+                    "type": "integer"
+                }
+            }
+        },
+        "utils.Limits": {
+            "type": "object",
+            "properties": {
+                "cpu_seconds": {
+                    "description": "CPUSeconds is the maximum CPU time in seconds",
+                    "type": "integer"
+                },
+                "file_size_mb": {
+                    "description": "FileSizeMB is the maximum size in megabytes of any file written",
+                    "type": "integer"
+                },
+                "memory_mb": {
+                    "description": "MemoryMB is the maximum memory in megabytes",
+                    "type": "integer"
+                },
+                "processes": {
+                    "description": "Processes is the maximum number of processes, only enforced with a cgroup:\nRLIMIT_NPROC counts every process of the user and root ignores it",
+                    "type": "integer"
+                }
+            }
+        },
+        "utils.ProcessInfo": {
+            "type": "object",
+            "properties": {
+                "exit_code": {
+                    "description": "ExitCode is the exit status of the process, -1 when it was killed by a signal",
+                    "type": "integer"
+                },
+                "max_rss_kb": {
+                    "description": "MaxRSSKB is the maximum resident set size of the process, or its largest child, in kilobytes",
+                    "type": "integer"
+                },
+                "signal": {
+                    "description": "Signal is the name of the signal that terminated the process, if any",
+                    "type": "string"
+                },
+                "system_time": {
+                    "description": "SystemTime is the system CPU time of the process and its children, in seconds",
+                    "type": "number"
+                },
+                "user_time": {
+                    "description": "UserTime is the user CPU time of the process and its children, in seconds",
+                    "type": "number"
+                },
+                "wall_time": {
+                    "description": "WallTime is how long the process ran, in seconds",
+                    "type": "number"
                 }
             }
         }
@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"encoding/base64"
+	"errors"
+	"jobd/domain/status"
+	"jobd/utils"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ArrayMaxSize is the largest number of child jobs an array job can have
+var ArrayMaxSize = int(utils.GetEnvInt64("JOB_ARRAY_MAX_SIZE", 1000))
+
+// Array turns a job into an array of child jobs sharing its input, one for each
+// index of the range `from`..`to` or for each of the parameter sets of `params`
+type Array struct {
+	From   int                 `json:"from"`
+	To     int                 `json:"to"`
+	Params []map[string]string `json:"params"`
+	// Children are the ids of the child jobs, Counts how many of them are in
+	// each status
+	Children []string       `json:"children"`
+	Counts   map[string]int `json:"counts"`
+}
+
+// Validate checks that the array describes either a range or parameter sets,
+// of a reasonable size; without params the array is the range, `{}` being the
+// single index 0
+func (a *Array) Validate() error {
+	tooLarge := errors.New("an array can have at most " + strconv.Itoa(ArrayMaxSize) + " jobs")
+	if len(a.Params) > 0 {
+		if a.From != 0 || a.To != 0 {
+			return errors.New("an array has either a range or params, not both")
+		}
+		if len(a.Params) > ArrayMaxSize {
+			return tooLarge
+		}
+	} else {
+		if a.From < 0 {
+			return errors.New("the array range cannot start below 0")
+		}
+		if a.To < a.From {
+			return errors.New("the end of the array range is before its start")
+		}
+		// The size is computed, listing the indexes of a huge range would not end
+		// well; from >= 0 so to-from cannot overflow
+		if a.To-a.From >= ArrayMaxSize {
+			return tooLarge
+		}
+	}
+	for _, params := range a.Params {
+		if err := checkEnv(params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Indexes are the indexes of the child jobs, the range or the position of
+// each parameter set; the array must be valid
+func (a *Array) Indexes() []int {
+	from, to := a.From, a.To
+	if len(a.Params) > 0 {
+		from, to = 0, len(a.Params)-1
+	}
+	indexes := []int{}
+	// i < from once i++ wraps around
+	for i := from; i <= to && i >= from; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// Expand builds the child jobs of an array job; they are copies of it named
+// `<id>-<index>` with the index, and the parameters if any, in their environment
+func (j *Job) Expand() []Job {
+	children := []Job{}
+	for n, index := range j.Array.Indexes() {
+		child := *j
+		child.ID = j.ID + "-" + strconv.Itoa(index)
+		child.Array = nil
+		child.ArrayID = j.ID
+		// The input is kept once, in the array job
+		child.Input = ""
+		child.DependsOn = append([]string{}, j.DependsOn...)
+
+		child.Env = map[string]string{}
+		maps.Copy(child.Env, j.Env)
+		if len(j.Array.Params) > 0 {
+			maps.Copy(child.Env, j.Array.Params[n])
+		}
+		child.Env["JOBD_ARRAY_ID"] = j.ID
+		child.Env["JOBD_ARRAY_INDEX"] = strconv.Itoa(index)
+
+		children = append(children, child)
+	}
+	return children
+}
+
+// input returns the base64 zip input of the job, the one of its array job for
+// the children of an array
+func (j *Job) input() (string, error) {
+	if j.Input != "" || j.ArrayID == "" {
+		return j.Input, nil
+	}
+	array := &Job{ID: j.ArrayID}
+	if array.Get() != nil {
+		return "", errors.New("array job " + j.ArrayID + " not found")
+	}
+	return array.Input, nil
+}
+
+// Aggregate sets the status of an array job from the ones of its children and
+// counts them by status: it is RUNNING while some are executed, QUEUED while the
+// others wait, then SUCCESS if all of them succeeded, FAILED if any failed and
+// CANCELLED otherwise
+func (j *Job) Aggregate() {
+	counts := map[string]int{}
+	for _, id := range j.Array.Children {
+		child := &Job{ID: id}
+		if child.Get() != nil {
+			counts[status.Deleted]++
+			continue
+		}
+		counts[child.Status]++
+	}
+	j.Array.Counts = counts
+
+	finished := 0
+	for s, n := range counts {
+		if status.IsTerminal(s) {
+			finished += n
+		}
+	}
+
+	switch {
+	case finished < len(j.Array.Children):
+		j.Status = status.Queued
+		for s := range counts {
+			if !status.IsTerminal(s) && !status.IsPending(s) {
+				j.Status = status.Running
+			}
+		}
+	case counts[status.Success] == len(j.Array.Children):
+		j.Status = status.Success
+	case counts[status.Failed] > 0 || counts[status.Timeout] > 0:
+		j.Status = status.Failed
+	default:
+		j.Status = status.Cancelled
+	}
+}
+
+// CombinedOutput gathers the outputs of the children of an array job in one
+// base64 encoded zip, the output of each child in a directory named after it
+func (j *Job) CombinedOutput() (string, error) {
+	dir, err := os.MkdirTemp("", "jobd-array-"+j.ID)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, id := range j.Array.Children {
+		child := &Job{ID: id}
+		if child.Get() != nil || child.Output == "" {
+			continue
+		}
+		err = utils.Unzip(child.Output, filepath.Join(dir, id))
+		if err != nil {
+			return "", errors.New("could not unzip the output of job " + id + ": " + err.Error())
+		}
+	}
+
+	bArr, err := utils.Zip(dir)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bArr), nil
+}
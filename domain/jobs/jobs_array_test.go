@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"jobd/domain/status"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestArray_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		array   Array
+		wantErr bool
+	}{
+		{name: "range", array: Array{From: 1, To: 10}, wantErr: false},
+		{name: "params", array: Array{Params: []map[string]string{{"MUTATION": "A12G"}, {"MUTATION": "K48R"}}}, wantErr: false},
+		{name: "single index", array: Array{}, wantErr: false},
+		{name: "negative start", array: Array{From: -3, To: 2}, wantErr: true},
+		{name: "both", array: Array{From: 1, To: 2, Params: []map[string]string{{"A": "1"}}}, wantErr: true},
+		{name: "reversed range", array: Array{From: 10, To: 1}, wantErr: true},
+		{name: "too large", array: Array{From: 1, To: ArrayMaxSize + 1}, wantErr: true},
+		{name: "huge range", array: Array{From: 0, To: 1e9}, wantErr: true},
+		{name: "up to the largest int", array: Array{From: 0, To: math.MaxInt}, wantErr: true},
+		{name: "too many params", array: Array{Params: make([]map[string]string, ArrayMaxSize+1)}, wantErr: true},
+		{name: "invalid param", array: Array{Params: []map[string]string{{"ADMIN_TOKEN": "x"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.array.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Array.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJob_Expand(t *testing.T) {
+	j := &Job{
+		ID:       "scan",
+		Status:   status.Queued,
+		Env:      map[string]string{"MODEL": "1brs"},
+		Priority: 5,
+		Input:    "BASE64",
+		Array:    &Array{Params: []map[string]string{{"MUTATION": "A12G"}, {"MUTATION": "K48R", "MODEL": "1ppe"}}},
+	}
+
+	got := j.Expand()
+
+	want := []Job{
+		{
+			ID:        "scan-0",
+			Status:    status.Queued,
+			Env:       map[string]string{"MODEL": "1brs", "MUTATION": "A12G", "JOBD_ARRAY_ID": "scan", "JOBD_ARRAY_INDEX": "0"},
+			Priority:  5,
+			ArrayID:   "scan",
+			DependsOn: []string{},
+		},
+		{
+			ID:        "scan-1",
+			Status:    status.Queued,
+			Env:       map[string]string{"MODEL": "1ppe", "MUTATION": "K48R", "JOBD_ARRAY_ID": "scan", "JOBD_ARRAY_INDEX": "1"},
+			Priority:  5,
+			ArrayID:   "scan",
+			DependsOn: []string{},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Job.Expand() = %+v, want %+v", got, want)
+	}
+	if j.Env["MUTATION"] != "" {
+		t.Errorf("Job.Expand() changed the environment of the array job: %v", j.Env)
+	}
+
+	ranged := &Job{ID: "range", Array: &Array{From: 3, To: 5}}
+	ids := []string{}
+	for _, child := range ranged.Expand() {
+		ids = append(ids, child.ID+"="+child.Env["JOBD_ARRAY_INDEX"])
+	}
+	if want := []string{"range-3=3", "range-4=4", "range-5=5"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Job.Expand() = %v, want %v", ids, want)
+	}
+}
+
+func TestJob_input(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	array := &Job{ID: "TestJob_input", Input: "BASE64", Array: &Array{}}
+	testutil.WriteRecord(t, array.ID, array)
+
+	tests := []struct {
+		name    string
+		j       *Job
+		want    string
+		wantErr bool
+	}{
+		{name: "own input", j: &Job{ID: "own", Input: "OWN"}, want: "OWN"},
+		{name: "child of an array", j: &Job{ID: "TestJob_input-0", ArrayID: array.ID}, want: "BASE64"},
+		{name: "array gone", j: &Job{ID: "TestJob_input-gone-0", ArrayID: "TestJob_input-gone"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.j.input()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Job.input() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Job.input() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_Aggregate(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	tests := []struct {
+		name       string
+		children   []string
+		wantStatus string
+	}{
+		{name: "pending", children: []string{status.Queued, status.Held, status.Success}, wantStatus: status.Queued},
+		{name: "running", children: []string{status.Queued, status.Running, status.Success}, wantStatus: status.Running},
+		{name: "succeeded", children: []string{status.Success, status.Success}, wantStatus: status.Success},
+		{name: "failed", children: []string{status.Success, status.Timeout, status.Cancelled}, wantStatus: status.Failed},
+		{name: "cancelled", children: []string{status.Success, status.Cancelled}, wantStatus: status.Cancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{ID: "TestJob_Aggregate-" + tt.name, Array: &Array{}}
+			want := map[string]int{}
+			for i, s := range tt.children {
+				child := &Job{ID: j.ID + "-" + string(rune('a'+i)), Status: s}
+				testutil.WriteRecord(t, child.ID, child)
+				j.Array.Children = append(j.Array.Children, child.ID)
+				want[s]++
+			}
+
+			j.Aggregate()
+			if j.Status != tt.wantStatus {
+				t.Errorf("Job.Aggregate() status = %v, want %v", j.Status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(j.Array.Counts, want) {
+				t.Errorf("Job.Aggregate() counts = %v, want %v", j.Array.Counts, want)
+			}
+		})
+	}
+}
+
+func TestJob_CombinedOutput(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	children := []Job{
+		{ID: "TestJob_CombinedOutput-1", Status: status.Success, Output: testutil.ZipBase64(t, map[string]string{"result.txt": "1"})},
+		{ID: "TestJob_CombinedOutput-2", Status: status.Failed},
+	}
+	j := &Job{ID: "TestJob_CombinedOutput", Array: &Array{}}
+	for _, child := range children {
+		testutil.WriteRecord(t, child.ID, &child)
+		j.Array.Children = append(j.Array.Children, child.ID)
+	}
+
+	output, err := j.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Job.CombinedOutput() error = %v", err)
+	}
+
+	testDir := "./test-combined-output"
+	defer os.RemoveAll(testDir)
+	_ = utils.Unzip(output, testDir)
+
+	got, err := os.ReadFile(testDir + "/TestJob_CombinedOutput-1/result.txt")
+	if err != nil || string(got) != "1" {
+		t.Errorf("combined output result.txt = %q (%v), want %q", got, err, "1")
+	}
+}
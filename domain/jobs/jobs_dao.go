@@ -3,11 +3,16 @@
 package jobs
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"jobd/datasource/db"
 	"jobd/domain/status"
 	"jobd/errors"
+	"sort"
+	"strconv"
 	"time"
+
+	"github.com/golang/glog"
 )
 
 func (j *Job) Save() *errors.RestErr {
@@ -19,29 +24,83 @@ func (j *Job) Save() *errors.RestErr {
 		return errors.NewBadRequestError("job already exists")
 	}
 
-	_ = db.Client.Write(db.NAME, j.ID, &j)
-	// if err != nil {
-	// 	return errors.NewInternalServerError("error saving job to database")
-	// }
+	err = db.Client.Write(db.NAME, j.ID, &j)
+	if err != nil {
+		return errors.NewInternalServerError("error saving job to database")
+	}
 	return nil
 }
 
 func (j *Job) Get() *errors.RestErr {
-	err := db.Client.Read(db.NAME, j.ID, &j)
+	// Decoding into j would write through the pointers, slices and maps it
+	// shares with its copies, like the attempt saved by Finish
+	record := Job{ctx: j.ctx, inputs: j.inputs, failure: j.failure}
+	err := db.Client.Read(db.NAME, j.ID, &record)
 	if err != nil {
 		return errors.NewInternalServerError("error getting job from database")
 	}
+	*j = record
 	return nil
 }
 
 func (j *Job) Delete() *errors.RestErr {
 	_ = db.Client.Delete(db.NAME, j.ID)
+	db.RemoveLock(j.ID)
 	// if err != nil {
 	// 	return errors.NewInternalServerError("error deleting job from database")
 	// }
 	return nil
 }
 
+// Lock takes an exclusive lock on the record of the job, shared with other jobd
+// processes using the same database; the returned function releases it
+func (j *Job) Lock() (func(), *errors.RestErr) {
+	unlock, err := db.Lock(j.ID)
+	if err != nil {
+		return nil, errors.NewInternalServerError("error locking job in database")
+	}
+	return unlock, nil
+}
+
+// Update reads the job, applies `fn` to it and saves it, all while the record is
+// locked so no concurrent change is lost; nothing is saved if `fn` fails
+func (j *Job) Update(fn func(j *Job) *errors.RestErr) *errors.RestErr {
+	unlock, err := j.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = j.Get()
+	if err != nil {
+		return err
+	}
+
+	err = fn(j)
+	if err != nil {
+		return err
+	}
+
+	j.LastUpdated = time.Now()
+	_ = db.Client.Write(db.NAME, j.ID, &j)
+	return nil
+}
+
+// Claim atomically moves a queued job to CLAIMED, owned by this Instance, before
+// any work is done on it; returns false if the job is not queued anymore, e.g.
+// someone else claimed it
+func (j *Job) Claim() bool {
+	err := j.Update(func(j *Job) *errors.RestErr {
+		if j.Status != status.Queued {
+			return errors.NewConflictError("job is not queued")
+		}
+		j.Status = status.Claimed
+		j.Owner = Instance
+		return nil
+	})
+	return err == nil
+}
+
 // ListQueued lists all jobs in the database with a status of "queued"
 func ListQueued() ([]Job, *errors.RestErr) {
 
@@ -69,6 +128,14 @@ func ListQueued() ([]Job, *errors.RestErr) {
 
 	}
 
+	// Highest priority first, then in the order they were submitted
+	sort.SliceStable(jobs, func(a, b int) bool {
+		if jobs[a].Priority != jobs[b].Priority {
+			return jobs[a].Priority > jobs[b].Priority
+		}
+		return jobs[a].Created.Before(jobs[b].Created)
+	})
+
 	return jobs, nil
 }
 
@@ -103,6 +170,49 @@ func ListSlurml() ([]Job, *errors.RestErr) {
 
 }
 
+// ListByStatus lists all jobs in the database with one of the given statuses
+func ListByStatus(statuses ...string) ([]Job, *errors.RestErr) {
+
+	// Read all records from the database
+	records, _ := db.Client.ReadAll(db.NAME)
+
+	jobs := []Job{}
+	for _, j := range records {
+		// Unmarshal the record into a Job
+		foundj := Job{}
+		_ = json.Unmarshal([]byte(j), &foundj)
+
+		for _, s := range statuses {
+			if foundj.Status == s {
+				jobs = append(jobs, foundj)
+				break
+			}
+		}
+	}
+
+	return jobs, nil
+}
+
+// ListUnfinished lists all jobs in the database that can still change
+func ListUnfinished() ([]Job, *errors.RestErr) {
+
+	// Read all records from the database
+	records, _ := db.Client.ReadAll(db.NAME)
+
+	jobs := []Job{}
+	for _, j := range records {
+		// Unmarshal the record into a Job
+		foundj := Job{}
+		_ = json.Unmarshal([]byte(j), &foundj)
+
+		if !status.IsTerminal(foundj.Status) {
+			jobs = append(jobs, foundj)
+		}
+	}
+
+	return jobs, nil
+}
+
 // ListOld lists all jobs in the database that are older than the specified time
 func ListOld(t time.Time) ([]Job, *errors.RestErr) {
 
@@ -144,6 +254,36 @@ func (j *Job) UpdateStatus(s string) *errors.RestErr {
 	return nil
 }
 
+// setStatus changes only the status in the record of the job, what is saved
+// in it meanwhile, like the progress of a running job, is kept
+func (j *Job) setStatus(s string) *errors.RestErr {
+	j.Status = s
+	record := &Job{ID: j.ID}
+	return record.Update(func(r *Job) *errors.RestErr {
+		r.Status = s
+		return nil
+	})
+}
+
+// beginRun marks the job RUNNING and clears what its record holds of a previous
+// attempt; from then on the run only writes its own fields, the progress and
+// the snapshots of the job being saved in the record meanwhile
+func (j *Job) beginRun() {
+	j.Status = status.Running
+	j.Progress = nil
+	j.Output = ""
+	record := &Job{ID: j.ID}
+	_ = record.Update(func(r *Job) *errors.RestErr {
+		r.Status = status.Running
+		r.Progress = nil
+		r.Output = ""
+		r.OutputManifest = nil
+		r.Process = nil
+		r.PostProcess = nil
+		return nil
+	})
+}
+
 // AddOutput adds output to the job
 func (j *Job) AddOutput(o string) *errors.RestErr {
 	j.Output = o
@@ -190,3 +330,152 @@ func (jl *JobList) ListOld(t time.Time) *errors.RestErr {
 
 	return nil
 }
+
+// ListDependents lists the jobs that depend on the job `id`
+func ListDependents(id string) ([]Job, *errors.RestErr) {
+
+	// Read all records from the database
+	records, _ := db.Client.ReadAll(db.NAME)
+
+	jobs := []Job{}
+	for _, j := range records {
+		// Unmarshal the record into a Job
+		foundj := Job{}
+		_ = json.Unmarshal([]byte(j), &foundj)
+
+		for _, parent := range foundj.DependsOn {
+			if parent == id {
+				jobs = append(jobs, foundj)
+				break
+			}
+		}
+	}
+
+	return jobs, nil
+}
+
+// Promote queues a scheduled job once its start time is reached, returns false
+// if it is not due yet or not scheduled anymore
+func (j *Job) Promote() bool {
+	err := j.Update(func(j *Job) *errors.RestErr {
+		if j.Status != status.Scheduled || !j.Ready() {
+			return errors.NewConflictError("job is not due")
+		}
+		j.Status = status.Queued
+		return nil
+	})
+	return err == nil
+}
+
+// Hold keeps a job waiting to be executed from being dispatched until it is released
+func (j *Job) Hold() *errors.RestErr {
+	return j.Update(func(j *Job) *errors.RestErr {
+		if j.Status == status.Held {
+			return errors.NewConflictError("job is already held")
+		}
+		if !status.IsPending(j.Status) {
+			return errors.NewConflictError("only waiting jobs can be held, job is " + j.Status)
+		}
+		j.Status = status.Held
+		return nil
+	})
+}
+
+// Release puts a held job back in line, it waits again for its parents or its
+// start time if it has any
+func (j *Job) Release() *errors.RestErr {
+	return j.Update(func(j *Job) *errors.RestErr {
+		if j.Status != status.Held {
+			return errors.NewConflictError("job is not held, it is " + j.Status)
+		}
+		j.Status = j.PendingStatus()
+		return nil
+	})
+}
+
+// PendingStatus is the status of a job waiting to be executed: WAITING if it has
+// parents, which are checked by the scheduler, SCHEDULED if its start time is not
+// reached yet and QUEUED otherwise
+func (j *Job) PendingStatus() string {
+	switch {
+	case len(j.DependsOn) > 0:
+		return status.Waiting
+	case !j.Ready():
+		return status.Scheduled
+	}
+	return status.Queued
+}
+
+// Finish ends the attempt of the job with the status `s`: the status, what the
+// attempt left in the job and, if it failed and the retry policy allows it, the
+// job queued again are saved in one update, so nobody sees the failure of a job
+// about to be retried as final. The attempt is recorded in the history of the job
+func (j *Job) Finish(s string) {
+	attempt := *j
+	var retryAt time.Time
+
+	_ = j.Update(func(j *Job) *errors.RestErr {
+		j.Status = s
+		j.Message = attempt.Message
+		j.Output = attempt.Output
+		j.OutputManifest = attempt.OutputManifest
+		j.Process = attempt.Process
+		j.PostProcess = attempt.PostProcess
+		j.Progress = attempt.Progress
+		j.failure = attempt.failure
+		retryAt = j.endAttempt()
+		return nil
+	})
+
+	if !retryAt.IsZero() {
+		glog.Info(j.ID, " failed, retrying at ", retryAt)
+	}
+
+	// The output of a finished job is downloaded from its archive
+	j.removeArchive(PartialArchive)
+	if status.IsTerminal(j.Status) && j.Output != "" {
+		bArr, err := base64.StdEncoding.DecodeString(j.Output)
+		if err == nil {
+			err = j.saveArchive(OutputArchive, bArr)
+		}
+		if err != nil {
+			glog.Warning("could not archive the output of ", j.ID, ": ", err)
+		}
+	}
+}
+
+// endAttempt records the attempt that just finished and queues the job again if
+// it is retried, returns when it is
+func (j *Job) endAttempt() time.Time {
+	// Cancelled, interrupted or still running; nothing to record
+	switch j.Status {
+	case status.Success, status.Failed, status.Timeout:
+	default:
+		return time.Time{}
+	}
+
+	class := j.failureClass()
+	attempt := Attempt{
+		Number:   len(j.Attempts) + 1,
+		Status:   j.Status,
+		Failure:  class,
+		Message:  j.Message,
+		Finished: time.Now(),
+		Process:  j.Process,
+	}
+	j.Attempts = append(j.Attempts, attempt)
+
+	if class == "" || attempt.Number >= j.Retry.MaxAttempts || !j.Retry.retries(class) {
+		return time.Time{}
+	}
+
+	retryAt := time.Now().Add(j.Retry.delay(attempt.Number))
+	j.NotBefore = retryAt
+	j.Message = "attempt " + strconv.Itoa(attempt.Number) + " of " + strconv.Itoa(j.Retry.MaxAttempts) +
+		" failed (" + class + "), retrying at " + retryAt.Format(time.RFC3339) + ": " + attempt.Message
+	j.Status = status.Queued
+	if !j.Ready() {
+		j.Status = status.Scheduled
+	}
+	return retryAt
+}
@@ -5,8 +5,11 @@ import (
 	"jobd/datasource/db"
 	"jobd/domain/status"
 	"jobd/errors"
+	"jobd/utils/testutil"
 	"os"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -216,6 +219,32 @@ func TestListQueued(t *testing.T) {
 	}
 }
 
+func TestListQueuedOrder(t *testing.T) {
+	testutil.CleanupDB(t)
+
+	now := time.Now()
+	queued := []Job{
+		{ID: "a-low", Status: status.Queued, Priority: -1, Created: now},
+		{ID: "b-old", Status: status.Queued, Created: now.Add(-time.Minute)},
+		{ID: "c-new", Status: status.Queued, Created: now},
+		{ID: "d-high", Status: status.Queued, Priority: 5, Created: now.Add(time.Minute)},
+	}
+	for _, j := range queued {
+		testutil.WriteRecord(t, j.ID, j)
+	}
+
+	got, _ := ListQueued()
+	ids := []string{}
+	for _, j := range got {
+		ids = append(ids, j.ID)
+	}
+
+	want := []string{"d-high", "b-old", "c-new", "a-low"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ListQueued() = %v, want %v", ids, want)
+	}
+}
+
 func TestJob_UpdateStatus(t *testing.T) {
 	// Create a job
 	j := &Job{ID: "TestJob_UpdateStatus"}
@@ -513,3 +542,207 @@ func TestJob_AddSlurmJobid(t *testing.T) {
 		})
 	}
 }
+
+func TestJob_Claim(t *testing.T) {
+	j := &Job{ID: "TestJob_Claim", Status: status.Queued}
+	testutil.WriteRecord(t, j.ID, j)
+
+	testutil.CleanupDB(t)
+
+	// Many schedulers try to claim the same job, only one can get it
+	var wg sync.WaitGroup
+	var claimed atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			candidate := &Job{ID: j.ID}
+			if candidate.Claim() {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed.Load() != 1 {
+		t.Errorf("Job.Claim() succeeded %d times, want 1", claimed.Load())
+	}
+
+	got := &Job{ID: j.ID}
+	_ = got.Get()
+	if got.Status != status.Claimed || got.Owner != Instance {
+		t.Errorf("Job.Claim() status = %v owner = %v, want %v owner %v", got.Status, got.Owner, status.Claimed, Instance)
+	}
+}
+
+func TestJob_Update(t *testing.T) {
+	j := &Job{ID: "TestJob_Update", Status: status.Queued}
+	testutil.WriteRecord(t, j.ID, j)
+
+	testutil.CleanupDB(t)
+
+	tests := []struct {
+		name       string
+		fn         func(j *Job) *errors.RestErr
+		want       *errors.RestErr
+		wantStatus string
+	}{
+		{
+			name: "TestJob_Update",
+			fn: func(j *Job) *errors.RestErr {
+				j.Status = status.Held
+				return nil
+			},
+			want:       nil,
+			wantStatus: status.Held,
+		},
+		{
+			name: "TestJob_Update_Rejected",
+			fn: func(j *Job) *errors.RestErr {
+				j.Status = status.Running
+				return errors.NewConflictError("rejected")
+			},
+			want:       errors.NewConflictError("rejected"),
+			wantStatus: status.Held,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{ID: "TestJob_Update"}
+			if got := j.Update(tt.fn); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Job.Update() = %v, want %v", got, tt.want)
+			}
+			saved := &Job{ID: "TestJob_Update"}
+			_ = saved.Get()
+			if saved.Status != tt.wantStatus {
+				t.Errorf("Job.Update() saved status = %v, want %v", saved.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestListByStatus(t *testing.T) {
+	running := &Job{ID: "TestListByStatus-running", Status: status.Running}
+	testutil.WriteRecord(t, running.ID, running)
+	prepared := &Job{ID: "TestListByStatus-prepared", Status: status.Prepared}
+	testutil.WriteRecord(t, prepared.ID, prepared)
+	success := &Job{ID: "TestListByStatus-success", Status: status.Success}
+	testutil.WriteRecord(t, success.ID, success)
+
+	testutil.CleanupDB(t)
+
+	got, err := ListByStatus(status.Running, status.Prepared)
+	if err != nil {
+		t.Errorf("ListByStatus() error = %v", err)
+	}
+
+	want := []Job{*prepared, *running}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListByStatus() = %v, want %v", got, want)
+	}
+}
+
+func TestListUnfinished(t *testing.T) {
+	held := &Job{ID: "TestListUnfinished-held", Status: status.Held}
+	testutil.WriteRecord(t, held.ID, held)
+	array := &Job{ID: "TestListUnfinished-array", Status: status.Array}
+	testutil.WriteRecord(t, array.ID, array)
+	failed := &Job{ID: "TestListUnfinished-failed", Status: status.Failed}
+	testutil.WriteRecord(t, failed.ID, failed)
+
+	testutil.CleanupDB(t)
+
+	got, err := ListUnfinished()
+	if err != nil {
+		t.Errorf("ListUnfinished() error = %v", err)
+	}
+
+	want := []Job{*array, *held}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListUnfinished() = %v, want %v", got, want)
+	}
+}
+
+func TestJob_HoldRelease(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		job         Job
+		wantHold    bool
+		wantRelease string
+	}{
+		{
+			name:        "queued",
+			job:         Job{ID: "TestJob_HoldRelease-queued", Status: status.Queued},
+			wantHold:    true,
+			wantRelease: status.Queued,
+		},
+		{
+			name:        "scheduled",
+			job:         Job{ID: "TestJob_HoldRelease-scheduled", Status: status.Scheduled, NotBefore: later},
+			wantHold:    true,
+			wantRelease: status.Scheduled,
+		},
+		{
+			name:        "waiting",
+			job:         Job{ID: "TestJob_HoldRelease-waiting", Status: status.Waiting, DependsOn: []string{"parent"}},
+			wantHold:    true,
+			wantRelease: status.Waiting,
+		},
+		{
+			name:        "scheduled and due",
+			job:         Job{ID: "TestJob_HoldRelease-due", Status: status.Scheduled, NotBefore: time.Now()},
+			wantHold:    true,
+			wantRelease: status.Queued,
+		},
+		{
+			name:     "running",
+			job:      Job{ID: "TestJob_HoldRelease-running", Status: status.Running},
+			wantHold: false,
+		},
+		{
+			name:     "already held",
+			job:      Job{ID: "TestJob_HoldRelease-held", Status: status.Held},
+			wantHold: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.WriteRecord(t, tt.job.ID, &tt.job)
+
+			j := &Job{ID: tt.job.ID}
+			if err := j.Hold(); (err == nil) != tt.wantHold {
+				t.Fatalf("Job.Hold() error = %v, want held %v", err, tt.wantHold)
+			}
+			if !tt.wantHold {
+				return
+			}
+
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != status.Held {
+				t.Errorf("Job status = %v, want %v", got.Status, status.Held)
+			}
+			if queued, _ := ListQueued(); len(queued) != 0 {
+				t.Errorf("ListQueued() = %v, want no held job", queued)
+			}
+
+			if err := j.Release(); err != nil {
+				t.Fatalf("Job.Release() error = %v", err)
+			}
+			_ = got.Get()
+			if got.Status != tt.wantRelease {
+				t.Errorf("Job status = %v, want %v", got.Status, tt.wantRelease)
+			}
+			if err := j.Release(); err == nil {
+				t.Errorf("Job.Release() of a released job error = nil, want an error")
+			}
+
+			_ = db.Client.Delete(db.NAME, j.ID)
+		})
+	}
+}
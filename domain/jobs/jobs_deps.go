@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"jobd/domain/status"
+	"jobd/errors"
+	"sort"
+)
+
+// Graph is the dependency graph of a job, edges go from a parent to the job
+// depending on it
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node is a job of a dependency graph
+type Node struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Edge says that `To` depends on `From`
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Resolve decides what happens to a waiting job given the state of its parents:
+// it is queued once all of them succeeded, failed if one of them failed and
+// cancelled if one of them was cancelled or does not exist anymore.
+// Returns the new status, or an empty one if the job keeps waiting
+func (j *Job) Resolve() string {
+	var next string
+
+	err := j.Update(func(j *Job) *errors.RestErr {
+		if j.Status != status.Waiting {
+			return errors.NewConflictError("job is not waiting")
+		}
+
+		ready := true
+		for _, id := range j.DependsOn {
+			parent := &Job{ID: id}
+			if parent.Get() != nil {
+				j.Message = "parent job " + id + " does not exist anymore"
+				next = status.Cancelled
+				break
+			}
+			if parent.Array != nil {
+				parent.Aggregate()
+			}
+
+			switch parent.Status {
+			case status.Success:
+				continue
+			case status.Failed, status.Timeout:
+				j.Message = "parent job " + id + " did not succeed (" + parent.Status + ")"
+				next = status.Failed
+			case status.Cancelled, status.Deleted:
+				j.Message = "parent job " + id + " was cancelled"
+				next = status.Cancelled
+			default:
+				ready = false
+				continue
+			}
+			break
+		}
+
+		if next == "" && ready {
+			j.Message = "all parent jobs succeeded"
+			next = status.Queued
+			if !j.Ready() {
+				next = status.Scheduled
+			}
+		}
+
+		if next == "" {
+			return errors.NewConflictError("job keeps waiting")
+		}
+		j.Status = next
+		return nil
+	})
+	if err != nil {
+		return ""
+	}
+
+	return next
+}
+
+// BuildGraph returns the jobs the job depends on, directly or not, the ones
+// depending on it and the dependencies between all of them
+func (j *Job) BuildGraph() Graph {
+	nodes := map[string]string{}
+	parents := map[string][]string{}
+
+	// Walk up to the ancestors
+	pending := []string{j.ID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if _, seen := nodes[id]; seen {
+			continue
+		}
+
+		job := &Job{ID: id}
+		if job.Get() != nil {
+			nodes[id] = status.Unknown
+			continue
+		}
+		nodes[id] = job.Status
+		parents[id] = job.DependsOn
+		pending = append(pending, job.DependsOn...)
+	}
+
+	// And down to the descendants
+	visited := map[string]bool{}
+	pending = []string{j.ID}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		children, _ := ListDependents(id)
+		for _, child := range children {
+			nodes[child.ID] = child.Status
+			parents[child.ID] = child.DependsOn
+			pending = append(pending, child.ID)
+		}
+	}
+
+	g := Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for id, s := range nodes {
+		g.Nodes = append(g.Nodes, Node{ID: id, Status: s})
+		for _, parent := range parents[id] {
+			if _, ok := nodes[parent]; ok {
+				g.Edges = append(g.Edges, Edge{From: parent, To: id})
+			}
+		}
+	}
+	sort.Slice(g.Nodes, func(a, b int) bool { return g.Nodes[a].ID < g.Nodes[b].ID })
+	sort.Slice(g.Edges, func(a, b int) bool {
+		if g.Edges[a].From != g.Edges[b].From {
+			return g.Edges[a].From < g.Edges[b].From
+		}
+		return g.Edges[a].To < g.Edges[b].To
+	})
+
+	return g
+}
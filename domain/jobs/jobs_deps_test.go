@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"jobd/domain/status"
+	"jobd/utils/testutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJob_Resolve(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	parents := map[string]string{
+		"TestJob_Resolve-success":   status.Success,
+		"TestJob_Resolve-running":   status.Running,
+		"TestJob_Resolve-failed":    status.Failed,
+		"TestJob_Resolve-cancelled": status.Cancelled,
+	}
+	for id, s := range parents {
+		testutil.WriteRecord(t, id, &Job{ID: id, Status: s})
+	}
+
+	tests := []struct {
+		name      string
+		dependsOn []string
+		notBefore time.Time
+		want      string
+	}{
+		{
+			name:      "all parents succeeded",
+			dependsOn: []string{"TestJob_Resolve-success"},
+			want:      status.Queued,
+		},
+		{
+			name:      "all parents succeeded before the start time",
+			dependsOn: []string{"TestJob_Resolve-success"},
+			notBefore: time.Now().Add(time.Hour),
+			want:      status.Scheduled,
+		},
+		{
+			name:      "a parent is running",
+			dependsOn: []string{"TestJob_Resolve-success", "TestJob_Resolve-running"},
+			want:      "",
+		},
+		{
+			name:      "a parent failed",
+			dependsOn: []string{"TestJob_Resolve-running", "TestJob_Resolve-failed"},
+			want:      status.Failed,
+		},
+		{
+			name:      "a parent was cancelled",
+			dependsOn: []string{"TestJob_Resolve-cancelled"},
+			want:      status.Cancelled,
+		},
+		{
+			name:      "a parent does not exist",
+			dependsOn: []string{"TestJob_Resolve-missing"},
+			want:      status.Cancelled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{ID: "TestJob_Resolve", Status: status.Waiting, DependsOn: tt.dependsOn, NotBefore: tt.notBefore}
+			testutil.WriteRecord(t, j.ID, j)
+
+			if got := j.Resolve(); got != tt.want {
+				t.Errorf("Job.Resolve() = %v, want %v", got, tt.want)
+			}
+
+			want := tt.want
+			if want == "" {
+				want = status.Waiting
+			}
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != want {
+				t.Errorf("Job status = %v, want %v", got.Status, want)
+			}
+		})
+	}
+}
+
+func TestJob_BuildGraph(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	// docking -> prodigy -> analysis <- docking
+	records := []*Job{
+		{ID: "docking", Status: status.Success},
+		{ID: "prodigy", Status: status.Running, DependsOn: []string{"docking"}},
+		{ID: "analysis", Status: status.Waiting, DependsOn: []string{"docking", "prodigy"}},
+		{ID: "unrelated", Status: status.Queued},
+	}
+	for _, j := range records {
+		testutil.WriteRecord(t, j.ID, j)
+	}
+
+	want := Graph{
+		Nodes: []Node{
+			{ID: "analysis", Status: status.Waiting},
+			{ID: "docking", Status: status.Success},
+			{ID: "prodigy", Status: status.Running},
+		},
+		Edges: []Edge{
+			{From: "docking", To: "analysis"},
+			{From: "docking", To: "prodigy"},
+			{From: "prodigy", To: "analysis"},
+		},
+	}
+
+	for _, id := range []string{"docking", "prodigy", "analysis"} {
+		j := &Job{ID: id}
+		if got := j.BuildGraph(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Job.BuildGraph() of %v = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package jobs
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -12,7 +13,9 @@ import (
 	"jobd/utils"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -20,10 +23,82 @@ import (
 
 var DEBUG = os.Getenv("DEBUG") == "true"
 
+// LogMaxSize is the maximum size in bytes of each log file of a job
+var LogMaxSize = utils.GetEnvInt64("LOG_MAX_SIZE", 10*1024*1024)
+
+// CGROUP_PATH is a cgroup v2 directory delegated to jobd, when set the memory and
+// process limits of the jobs are enforced with a group per job
+var CGROUP_PATH = os.Getenv("CGROUP_PATH")
+
+// KillGrace is how long a job has to exit after SIGTERM before it is killed
+var KillGrace = time.Duration(utils.GetEnvInt64("JOB_KILL_GRACE", 10)) * time.Second
+
+// Instance names this jobd among the ones sharing the database, the jobs it
+// claims are its own until they are finished or requeued
+var Instance = instanceName(os.Getenv("JOBD_INSTANCE"))
+
+// instanceName is `name`, or the host name when it is not set
+func instanceName(name string) string {
+	if name != "" {
+		return name
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		glog.Warning("could not get the host name, set JOBD_INSTANCE: ", err)
+	}
+	return host
+}
+
+const (
+	StdoutLog = "stdout.log"
+	StderrLog = "stderr.log"
+)
+
+var (
+	// ErrTimeout is the cause given to a job that exceeds its time limit
+	ErrTimeout = errors.New("job exceeded its time limit")
+	// ErrCancelled is the cause given to a job cancelled by the user
+	ErrCancelled = errors.New("job was cancelled")
+	// ErrInterrupted is the cause given to the jobs still running when jobd shuts down
+	ErrInterrupted = errors.New("job was interrupted by a shutdown")
+)
+
+// Upload is a job as it is submitted to `/api/upload`
 type Upload struct {
-	Id     string `json:"id"`
-	Input  string `json:"input"`
-	Slurml bool   `json:"slurml"`
+	// Id is a unique job identifier chosen by the client
+	Id string `json:"id"`
+	// Input is a base64 encoded `.zip` file with a `run.sh` script, or a
+	// `jobd.json`/`jobd.yaml` manifest describing the command, the input data
+	// and optionally a `post.sh` post-processing the results once the job succeeded
+	Input string `json:"input"`
+	// Slurml redirects the job to the `slurml` endpoint (wip)
+	Slurml bool `json:"slurml"`
+	// Timeout is the wall-clock limit of the job in seconds
+	Timeout int `json:"timeout"`
+	// Limits are the resources the job can use, capped by the server
+	Limits utils.Limits `json:"limits"`
+	// Env are extra environment variables of the job
+	Env map[string]string `json:"env"`
+	// Retry says if and when the job is executed again after a failure
+	Retry RetryPolicy `json:"retry"`
+	// Priority of the job, the highest are executed first; capped by the server,
+	// only admins raise it by default
+	Priority int `json:"priority"`
+	// NotBefore is the earliest time the job can start
+	NotBefore time.Time `json:"not_before"`
+	// DependsOn are the jobs that must succeed before this one starts
+	DependsOn []string `json:"depends_on"`
+	// InputFrom is a job whose output is the input of this one, `input` is then
+	// an optional overlay
+	InputFrom string `json:"input_from"`
+	// Hold submits the job as HELD, it is not executed until an admin releases it
+	Hold bool `json:"hold"`
+	// Array expands the upload into child jobs, one per index of a range or per
+	// parameter set
+	Array *Array `json:"array"`
+	// OutputSelection chooses the files that go in the output: its `include`
+	// patterns replace the server ones, its `exclude` patterns are added to them
+	OutputSelection OutputSelection `json:"output_selection"`
 }
 
 type Job struct {
@@ -31,11 +106,40 @@ type Job struct {
 	ID          string
 	Status      string
 	Path        string
+	LogPath     string
 	Input       string
 	Output      string
 	Message     string
 	SlurmID     int
 	Slurml      bool
+	Timeout     int
+	Limits      utils.Limits
+	Manifest    *Manifest
+	Env         map[string]string
+	Process     *utils.ProcessInfo
+	Retry       RetryPolicy
+	Attempts    []Attempt
+	NotBefore   time.Time
+	Priority    int
+	Created     time.Time
+	DependsOn   []string
+	InputFrom   string
+	StartHeld   bool
+	Array       *Array
+	ArrayID     string
+	Progress    *Progress
+	PostProcess *utils.ProcessInfo
+	Owner       string
+
+	OutputSelection OutputSelection
+	OutputManifest  []OutputFile
+
+	// ctx is done when the execution of the job must stop
+	ctx context.Context
+	// inputs are the input files as they were unpacked
+	inputs map[string]fileState
+	// failure is the failure class of an attempt that is not told by how it ended
+	failure string
 }
 
 type JobList struct {
@@ -58,18 +162,59 @@ func (j *Job) Prepare() error {
 	// Create the job directory
 	_ = os.MkdirAll(j.Path, 0755)
 
-	// Copy the input file to the job directory
-	err := utils.Unzip(j.Input, j.Path)
+	var err error
+
+	// Start from the output of another job, the input is laid over it
+	if j.InputFrom != "" {
+		err = j.unpackInputFrom()
+		if err != nil {
+			j.prepareFailed("could not use the output of job " + j.InputFrom + ": " + err.Error())
+			return err
+		}
+	}
+
+	input, err := j.input()
 	if err != nil {
-		j.AddMessage("could not unzip file, is it base64 encoded? error: " + err.Error())
-		j.UpdateStatus(status.Failed)
+		j.prepareFailed("could not get the input of the job: " + err.Error())
 		return err
 	}
 
+	// Copy the input file to the job directory
+	if input != "" || j.InputFrom == "" {
+		err = utils.Unzip(input, j.Path)
+		if err != nil {
+			j.prepareFailed("could not unzip file, is it base64 encoded? error: " + err.Error())
+			return err
+		}
+	}
+
+	// Inputs the job does not modify can be left out of the output
+	if j.OutputSelection.ExcludeInputs {
+		j.recordInputs()
+	}
+
+	// A manifest replaces the default run.sh entrypoint
+	if j.Manifest == nil {
+		j.Manifest, err = LoadManifest(j.Path)
+		if err != nil {
+			j.prepareFailed(err.Error())
+			return err
+		}
+	}
+
+	if j.Manifest != nil {
+		err = j.checkCommand()
+		if err != nil {
+			j.prepareFailed(err.Error())
+			return err
+		}
+		j.UpdateStatus(status.Prepared)
+		return nil
+	}
+
 	// Check if run.sh exists
 	if _, err := os.Stat(j.Path + "/run.sh"); os.IsNotExist(err) {
-		j.AddMessage("run.sh does not exist in the input file")
-		j.UpdateStatus(status.Failed)
+		j.prepareFailed("run.sh does not exist in the input file")
 		return err
 	}
 
@@ -81,23 +226,106 @@ func (j *Job) Prepare() error {
 	return nil
 }
 
-// Run executes the job by running the run.sh script in the job directory
+// prepareFailed ends the attempt of a job that could not be prepared
+func (j *Job) prepareFailed(message string) {
+	j.AddMessage(message)
+	j.failure = FailurePrepare
+	j.Finish(status.Failed)
+}
+
+// unpackInputFrom decompresses the output of the job named by InputFrom in the job directory
+func (j *Job) unpackInputFrom() error {
+	source := &Job{ID: j.InputFrom}
+	if source.Get() != nil {
+		return errors.New("job not found")
+	}
+	if source.Status != status.Success {
+		return errors.New("job did not succeed, it is " + source.Status)
+	}
+	if source.Output == "" {
+		return errors.New("job has no output")
+	}
+	return utils.Unzip(source.Output, j.Path)
+}
+
+// Run executes the job by running the run.sh script, or the command of its
+// manifest, in the job directory, then its post-processing steps if it succeeded
 func (j *Job) Run() string {
-	glog.Infof("Going into %s and executing run.sh", j.Path)
+	command, args := j.command()
+	glog.Infof("Going into %s and executing %s", j.Path, command)
+
+	j.beginRun()
+
+	stdout, stderr, closeLogs := j.openLogs()
+
+	// The job reports its progress in its stdout or in its progress file
+	progress := j.trackProgress()
+	env := j.environ()
+	if progress.file != "" {
+		env = append(env, "JOBD_PROGRESS="+progress.file)
+	}
+	var output io.Writer = progress
+
+	// The job cannot touch what it does not own when it runs as another user
+	j.handOver(progress.file)
+	if stdout != nil {
+		output = io.MultiWriter(stdout, progress)
+	}
 
 	// Run the job
-	errRun := utils.RunScript(j.Path, "run.sh")
+	script := utils.Script{
+		Dir:        j.Path,
+		Command:    command,
+		Args:       args,
+		Env:        env,
+		Stdout:     output,
+		Stderr:     stderr,
+		Grace:      KillGrace,
+		Limits:     j.Limits,
+		Cgroup:     CGROUP_PATH,
+		Credential: JobUser,
+	}
+	// What the job publishes is shown while it runs
+	stopPartials := j.snapshotPartials(progress)
+
+	ctx, cancel := j.runContext()
+	errRun := script.Run(ctx)
+	stopPartials()
+	j.Process = script.Info
+
+	// A job that does not produce what it promised has failed
+	if errRun == nil {
+		if missing := j.missingOutputs(); len(missing) > 0 {
+			errRun = errors.New("expected outputs were not produced: " + strings.Join(missing, ", "))
+		}
+	}
+
+	// The results of a job that succeeded are post-processed, a failure there,
+	// even a timeout, is told apart from one of the job
+	var errPost error
+	if errRun == nil {
+		errPost = j.postProcess(ctx, script)
+	}
+	postFailed := errPost != nil
+	if postFailed {
+		errRun = errPost
+	}
 
-	// Compress the output regardless of the error
-	bArr, _ := utils.Zip(j.Path)
+	cancel()
+	closeLogs()
+	j.Progress = progress.stop()
+
+	// Compress the selected output regardless of the error
+	bArr, _ := j.zipOutput()
+	j.OutputManifest, _ = j.outputManifest(bArr)
 	// if err != nil {
 	// 	j.UpdateStatus(status.Failure)
 	// 	return err
 	// }
 
 	// glog.Info(bArr)
-	encodedOutput := base64.StdEncoding.EncodeToString(bArr)
-	j.AddOutput(encodedOutput)
+	// Saved with the final status
+	j.Output = base64.StdEncoding.EncodeToString(bArr)
 
 	// Delete the job directory
 	if DEBUG {
@@ -106,49 +334,143 @@ func (j *Job) Run() string {
 		_ = os.RemoveAll(j.Path)
 	}
 
-	if errRun != nil {
+	switch {
+	case errors.Is(errRun, ErrCancelled), errors.Is(errRun, ErrInterrupted):
+		j.stopped(errRun)
+	case postFailed:
+		glog.Info(j.ID, " post-processing failed: ", errRun.Error())
+		j.AddMessage("job finished successfully but its post-processing failed, error: " + errRun.Error())
+		j.Finish(status.Failed)
+	case errors.Is(errRun, ErrTimeout):
+		glog.Info(j.ID, " timed out after ", j.Timeout, " seconds")
+		j.AddMessage("job exceeded its time limit of " + strconv.Itoa(j.Timeout) + "s and was terminated")
+		j.Finish(status.Timeout)
+	case errors.As(errRun, new(*utils.LimitError)):
+		glog.Info(j.ID, " went over its limits: ", errRun.Error())
+		j.AddMessage(errRun.Error())
+		j.Finish(status.Failed)
+	case errRun != nil:
 		glog.Info(j.ID, " Error running script: ", errRun.Error())
 		j.AddMessage("could not finish the job, error: " + errRun.Error())
-		j.UpdateStatus(status.Failed)
-	} else {
+		j.Finish(status.Failed)
+	default:
 		glog.Info(j.ID, " finished successfully")
 		j.AddMessage("job finished successfully")
-		j.UpdateStatus(status.Success)
+		j.Finish(status.Success)
 	}
 
 	return j.Status
 }
 
+// stopped records that the execution of the job was stopped; cancelled jobs are
+// final while the ones interrupted by a shutdown go back to the queue
+func (j *Job) stopped(cause error) {
+	if errors.Is(cause, ErrInterrupted) {
+		glog.Info(j.ID, " was interrupted, requeueing it")
+		j.AddMessage("job was interrupted by a jobd shutdown and requeued")
+		j.Finish(status.Queued)
+		return
+	}
+
+	glog.Info(j.ID, " was cancelled")
+	j.AddMessage("job was cancelled")
+	j.Finish(status.Cancelled)
+}
+
+// runContext returns the context the job runs under, it expires with ErrTimeout
+// when the job has a time limit
+func (j *Job) runContext() (context.Context, context.CancelFunc) {
+	parent := j.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	if j.Timeout > 0 {
+		return context.WithTimeoutCause(parent, time.Duration(j.Timeout)*time.Second, ErrTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// StdoutPath returns the path of the file holding the stdout of the job
+func (j *Job) StdoutPath() string {
+	return filepath.Join(j.LogPath, StdoutLog)
+}
+
+// StderrPath returns the path of the file holding the stderr of the job
+func (j *Job) StderrPath() string {
+	return filepath.Join(j.LogPath, StderrLog)
+}
+
+// openLogs creates the log files of the job and returns size-capped writers for
+// stdout and stderr; the logs live outside of the job directory so they are kept
+// after it is deleted
+func (j *Job) openLogs() (io.Writer, io.Writer, func()) {
+	if j.LogPath == "" {
+		return nil, nil, func() {}
+	}
+
+	if err := os.MkdirAll(j.LogPath, 0755); err != nil {
+		glog.Error("could not create the log directory: ", err.Error())
+		return nil, nil, func() {}
+	}
+
+	stdout, err := os.Create(j.StdoutPath())
+	if err != nil {
+		glog.Error("could not create the stdout log: ", err.Error())
+		return nil, nil, func() {}
+	}
+
+	stderr, err := os.Create(j.StderrPath())
+	if err != nil {
+		glog.Error("could not create the stderr log: ", err.Error())
+		stdout.Close()
+		return nil, nil, func() {}
+	}
+
+	closeLogs := func() {
+		stdout.Close()
+		stderr.Close()
+	}
+
+	return utils.NewCappedWriter(stdout, LogMaxSize), utils.NewCappedWriter(stderr, LogMaxSize), closeLogs
+}
+
 // PostToSlurml posts the job to the SLURML API
 func (j *Job) PostToSlurml() string {
 	// Get the SLURML API URL
-	slurmAPIURL := os.Getenv("SLURML_API_URL")
+	slurmAPIURL := SLURML_API_URL
 	if slurmAPIURL == "" {
 		glog.Error("SLURML_API_URL is not set")
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
 	slurmAPIURL = slurmAPIURL + "/api/submit"
 
 	// Get the TOKEN for the SLURML API
-	slurmAPIToken := os.Getenv("SLURML_API_TOKEN")
+	slurmAPIToken := SLURML_API_TOKEN
 	if slurmAPIToken == "" {
 		glog.Error("SLURML_API_TOKEN is not set")
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
+		return j.Status
+	}
+
+	input, err := j.input()
+	if err != nil {
+		glog.Error("could not get the input of the job: ", err.Error())
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
 	// Create the JSON payload
 	jsonStr := []byte(
 		`{
-			"payload": "` + j.Input + `"
+			"payload": "` + input + `"
 		}`)
 
 	req, err := http.NewRequest("POST", slurmAPIURL, bytes.NewBuffer(jsonStr))
 	if err != nil {
 		glog.Error("could not post the job to the SLURML API: ", err.Error())
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
@@ -159,7 +481,7 @@ func (j *Job) PostToSlurml() string {
 	r, err := client.Do(req)
 	if err != nil {
 		glog.Error("could not post the job to the SLURML API: ", err.Error())
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
@@ -168,7 +490,7 @@ func (j *Job) PostToSlurml() string {
 	// Check the response
 	if r.StatusCode != http.StatusCreated {
 		glog.Error("could not post the job to the SLURML API: ", r.StatusCode)
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
@@ -179,7 +501,7 @@ func (j *Job) PostToSlurml() string {
 	err = json.Unmarshal(body, &slurmResponse)
 	if err != nil {
 		glog.Error("could not unmarshal the response from the SLURML API: ", err.Error())
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
@@ -196,14 +518,14 @@ func (j *Job) GetFromSlurml() string {
 	glog.Info("Updating job " + j.ID)
 
 	// Retrieve the job from the SLURML API
-	slurmAPIURL := os.Getenv("SLURML_API_URL")
+	slurmAPIURL := SLURML_API_URL
 	if slurmAPIURL == "" {
 		glog.Error("SLURML_API_URL is not set")
 		return status.Failed
 	}
 
 	// Get the TOKEN for the SLURML API
-	slurmAPIToken := os.Getenv("SLURML_API_TOKEN")
+	slurmAPIToken := SLURML_API_TOKEN
 	if slurmAPIToken == "" {
 		glog.Error("SLURML_API_TOKEN is not set")
 		return status.Failed
@@ -213,7 +535,7 @@ func (j *Job) GetFromSlurml() string {
 	req, err := http.NewRequest("GET", slurmAPIURL+"/api/download/"+strconv.Itoa(j.SlurmID), nil)
 	if err != nil {
 		glog.Error("could not get the job from the SLURML API: ", err.Error())
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
@@ -224,7 +546,7 @@ func (j *Job) GetFromSlurml() string {
 	r, err := client.Do(req)
 	if err != nil {
 		glog.Error("could not get the job from the SLURML API: ", err.Error())
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 
@@ -237,7 +559,7 @@ func (j *Job) GetFromSlurml() string {
 
 	case http.StatusInternalServerError:
 		glog.Error("Job " + j.ID + " has failed with  status code 500")
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 
 	case http.StatusPartialContent:
@@ -246,12 +568,16 @@ func (j *Job) GetFromSlurml() string {
 		slurmReponse, err := GetSlurmResponse(r)
 		if err != nil {
 			glog.Error("could not unmarshal the response from the SLURML API: ", err.Error())
-			j.UpdateStatus(status.Failed)
+			j.Finish(status.Failed)
 			return status.Failed
 		}
 
-		j.AddOutput(slurmReponse.Output)
-		j.UpdateStatus(status.Partial)
+		// The snapshot is kept out of the record
+		j.Status = status.Partial
+		if bArr, err := base64.StdEncoding.DecodeString(slurmReponse.Output); err == nil {
+			_ = j.saveArchive(PartialArchive, bArr)
+		}
+		j.setStatus(status.Partial)
 		return j.Status
 
 	case http.StatusOK:
@@ -260,29 +586,90 @@ func (j *Job) GetFromSlurml() string {
 		slurmReponse, err := GetSlurmResponse(r)
 		if err != nil {
 			glog.Error("could not unmarshal the response from the SLURML API: ", err.Error())
-			j.UpdateStatus(status.Failed)
+			j.Finish(status.Failed)
 			return status.Failed
 		}
 
 		j.AddOutput(slurmReponse.Output)
-		j.UpdateStatus(status.Success)
+		j.Finish(status.Success)
 		return j.Status
 
 	default:
 		glog.Error("could not get the job from the SLURML API: ", r.StatusCode)
-		j.UpdateStatus(status.Failed)
+		j.Finish(status.Failed)
 		return j.Status
 	}
 }
 
+// SlurmlCancelTimeout bounds the request cancelling a job on slurml, the record
+// of the job stays locked meanwhile
+var SlurmlCancelTimeout = 10 * time.Second
+
+// CancelOnSlurml asks the SLURML API to cancel the job
+func (j *Job) CancelOnSlurml() error {
+	slurmAPIURL := SLURML_API_URL
+	if slurmAPIURL == "" {
+		return errors.New("SLURML_API_URL is not set")
+	}
+
+	slurmAPIToken := SLURML_API_TOKEN
+	if slurmAPIToken == "" {
+		return errors.New("SLURML_API_TOKEN is not set")
+	}
+
+	req, err := http.NewRequest("POST", slurmAPIURL+"/api/cancel/"+strconv.Itoa(j.SlurmID), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Authorization", slurmAPIToken)
+
+	client := &http.Client{Timeout: SlurmlCancelTimeout}
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusAccepted && r.StatusCode != http.StatusNoContent {
+		return errors.New("SLURML API answered with status code " + strconv.Itoa(r.StatusCode))
+	}
+
+	return nil
+}
+
 // Execute prepares and runs the job
 func (j *Job) Execute() error {
+	// Register the job so it can be cancelled while it executes
+	ctx, done, err := j.start()
+	if err != nil {
+		glog.Info(j.ID, " ", err.Error())
+		return err
+	}
+	defer done()
+	j.ctx = ctx
+	// What is known of the process belongs to the previous attempt
+	j.Process = nil
+	j.PostProcess = nil
+	j.OutputManifest = nil
+	j.failure = ""
+	j.removeArchive(OutputArchive)
+	j.removeArchive(PartialArchive)
+
 	// Prepare the job
-	err := j.Prepare()
+	err = j.Prepare()
 	if err != nil {
 		return err
 	}
 
+	// The job might have been stopped while it was being prepared
+	if ctx.Err() != nil {
+		_ = os.RemoveAll(j.Path)
+		j.stopped(context.Cause(ctx))
+		return context.Cause(ctx)
+	}
+
 	// Run the job
 	if j.Slurml {
 		runStatus := j.PostToSlurml()
@@ -291,7 +678,7 @@ func (j *Job) Execute() error {
 		}
 	} else {
 		runStatus := j.Run()
-		if runStatus == status.Failed {
+		if runStatus != status.Success {
 			return errors.New("job failed")
 		}
 	}
@@ -305,9 +692,68 @@ func (j *Job) Validate() error {
 		return errors.New("job id is required")
 	}
 
+	// The id is used to name files and directories
+	if !ValidID(j.ID) {
+		return errors.New("job id cannot contain path separators")
+	}
+
+	if j.Timeout < 0 {
+		return errors.New("timeout must be a positive number of seconds")
+	}
+
+	if j.Limits.MemoryMB < 0 || j.Limits.CPUSeconds < 0 || j.Limits.FileSizeMB < 0 || j.Limits.Processes < 0 {
+		return errors.New("limits must be positive numbers")
+	}
+
+	if err := checkEnv(j.Env); err != nil {
+		return err
+	}
+
+	if err := j.Retry.Validate(); err != nil {
+		return err
+	}
+
+	for _, parent := range j.DependsOn {
+		if parent == j.ID {
+			return errors.New("a job cannot depend on itself")
+		}
+		if !ValidID(parent) {
+			return errors.New("invalid parent job id " + parent)
+		}
+	}
+
+	if j.InputFrom == j.ID {
+		return errors.New("a job cannot use its own output as input")
+	}
+	if j.InputFrom != "" && !ValidID(j.InputFrom) {
+		return errors.New("invalid input_from job id " + j.InputFrom)
+	}
+
+	if j.Array != nil {
+		if err := j.Array.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := j.OutputSelection.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// ValidID reports if `id` can name a job, ids are used to name files and directories
+func ValidID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && id != "." && id != ".."
+}
+
+// HideInternals clears the fields of a job that are not meant for the client
+func (j *Job) HideInternals() {
+	j.Input = ""
+	j.Path = ""
+	j.LogPath = ""
+}
+
 // AddMessage adds a message to the job
 func (j *Job) AddMessage(message string) {
 	j.Message = message
@@ -8,7 +8,13 @@ import (
 	"io"
 	"jobd/datasource/db"
 	"jobd/domain/status"
+	"jobd/errors"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -165,6 +171,7 @@ func TestJob_Run(t *testing.T) {
 				Output:      tt.fields.Output,
 				LastUpdated: tt.fields.LastUpdated,
 			}
+			_ = db.Client.Write(db.NAME, j.ID, j)
 			got := j.Run()
 			if got != tt.want {
 				t.Errorf("Job.Run() = %v, want %v", got, tt.want)
@@ -322,6 +329,9 @@ func TestJob_Validate(t *testing.T) {
 		Input       string
 		Output      string
 		LastUpdated time.Time
+		Env         map[string]string
+		InputFrom   string
+		Selection   OutputSelection
 	}
 	tests := []struct {
 		name    string
@@ -342,6 +352,45 @@ func TestJob_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "TestJob_Validate with a path as ID",
+			fields: fields{
+				ID: "../../etc",
+			},
+			wantErr: true,
+		},
+		{
+			name: "TestJob_Validate with a reserved env",
+			fields: fields{
+				ID:  "TestJob_Validate",
+				Env: map[string]string{"SLURML_API_TOKEN": "abc"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "TestJob_Validate with its own output as input",
+			fields: fields{
+				ID:        "TestJob_Validate",
+				InputFrom: "TestJob_Validate",
+			},
+			wantErr: true,
+		},
+		{
+			name: "TestJob_Validate with a path as input_from",
+			fields: fields{
+				ID:        "TestJob_Validate",
+				InputFrom: "../other",
+			},
+			wantErr: true,
+		},
+		{
+			name: "TestJob_Validate with an invalid output pattern",
+			fields: fields{
+				ID:        "TestJob_Validate",
+				Selection: OutputSelection{Exclude: []string{"[scratch"}},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -352,6 +401,10 @@ func TestJob_Validate(t *testing.T) {
 				Input:       tt.fields.Input,
 				Output:      tt.fields.Output,
 				LastUpdated: tt.fields.LastUpdated,
+				Env:         tt.fields.Env,
+				InputFrom:   tt.fields.InputFrom,
+
+				OutputSelection: tt.fields.Selection,
 			}
 			if err := j.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Job.Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -406,3 +459,335 @@ func TestJob_AddMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestJob_RunLogs(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-logs"
+	logDir := "./test-run-logs-output"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+	defer os.RemoveAll(logDir)
+
+	d1 := []byte("#!/bin/bash\necho \"hello\"\necho \"oops\" >&2\nexit 1")
+	err := os.WriteFile(testDir+"/run.sh", d1, 0775)
+	if err != nil {
+		t.Errorf("Job.Run() error = %v", err)
+	}
+
+	j := &Job{ID: "TestJob_RunLogs", Path: testDir, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+	if got := j.Run(); got != status.Failed {
+		t.Errorf("Job.Run() = %v, want %v", got, status.Failed)
+	}
+
+	stdout, err := os.ReadFile(j.StdoutPath())
+	if err != nil {
+		t.Errorf("could not read stdout log: %v", err)
+	}
+	if string(stdout) != "hello\n" {
+		t.Errorf("stdout log = %q, want %q", stdout, "hello\n")
+	}
+
+	stderr, err := os.ReadFile(j.StderrPath())
+	if err != nil {
+		t.Errorf("could not read stderr log: %v", err)
+	}
+	if string(stderr) != "oops\n" {
+		t.Errorf("stderr log = %q, want %q", stderr, "oops\n")
+	}
+}
+
+func TestJob_RunProcess(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-process"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	d1 := []byte("#!/bin/bash\nexit 3")
+	err := os.WriteFile(testDir+"/run.sh", d1, 0775)
+	if err != nil {
+		t.Errorf("Job.Run() error = %v", err)
+	}
+
+	j := &Job{ID: "TestJob_RunProcess", Path: testDir}
+	testutil.WriteRecord(t, j.ID, j)
+	if got := j.Run(); got != status.Failed {
+		t.Errorf("Job.Run() = %v, want %v", got, status.Failed)
+	}
+
+	got := &Job{ID: j.ID}
+	_ = got.Get()
+	if got.Process == nil || got.Process.ExitCode != 3 || got.Process.Signal != "" {
+		t.Errorf("Job.Process = %+v, want exit code 3", got.Process)
+	}
+}
+
+func TestJob_RunKeepsRecord(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-keeps-record"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	_ = os.WriteFile(testDir+"/run.sh", []byte("#!/bin/bash\nwhile [ ! -f proceed ]; do sleep 0.01; done"), 0775)
+
+	j := &Job{ID: "TestJob_RunKeepsRecord", Path: testDir, Priority: 1}
+	testutil.WriteRecord(t, j.ID, j)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- j.Run()
+	}()
+
+	// Changed in the record while the job runs
+	record := &Job{ID: j.ID}
+	deadline := time.Now().Add(5 * time.Second)
+	for record.Get() == nil && record.Status != status.Running && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = record.Update(func(r *Job) *errors.RestErr {
+		r.Priority = 7
+		return nil
+	})
+	_ = os.WriteFile(testDir+"/proceed", nil, 0644)
+
+	select {
+	case s := <-done:
+		if s != status.Success {
+			t.Errorf("Job.Run() = %v, want %v", s, status.Success)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Job.Run() did not return")
+	}
+
+	got := &Job{ID: j.ID}
+	_ = got.Get()
+	if got.Priority != 7 {
+		t.Errorf("Job priority = %v, want the 7 saved while it ran", got.Priority)
+	}
+}
+
+func TestJob_RunTimeout(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-timeout"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	d1 := []byte("#!/bin/bash\nsleep 60 &\nwait")
+	err := os.WriteFile(testDir+"/run.sh", d1, 0775)
+	if err != nil {
+		t.Errorf("Job.Run() error = %v", err)
+	}
+
+	j := &Job{ID: "TestJob_RunTimeout", Path: testDir, Timeout: 1}
+
+	start := time.Now()
+	testutil.WriteRecord(t, j.ID, j)
+	if got := j.Run(); got != status.Timeout {
+		t.Errorf("Job.Run() = %v, want %v", got, status.Timeout)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Job.Run() took %v, the job was not terminated", elapsed)
+	}
+	if j.Message != "job exceeded its time limit of 1s and was terminated" {
+		t.Errorf("Job.Run() message = %v", j.Message)
+	}
+}
+
+func TestJob_ExecuteCancel(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-execute-cancel"
+	defer os.RemoveAll(testDir)
+
+	j := &Job{
+		ID:    "TestJob_ExecuteCancel",
+		Path:  testDir,
+		Input: testutil.ZipBase64(t, map[string]string{"run.sh": "#!/bin/bash\necho partial > partial.txt\nsleep 60 &\nwait"}),
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- j.Execute()
+	}()
+
+	// Wait for the job to be running
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := &Job{ID: j.ID}
+		_ = got.Get()
+		if got.Status == status.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not start running, status = %v", got.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	done, ok := Cancel(j.ID, ErrCancelled)
+	if !ok {
+		t.Fatalf("Cancel() = false, want true")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("job was not stopped")
+	}
+
+	if err := <-errCh; err == nil {
+		t.Errorf("Job.Execute() error = nil, want an error")
+	}
+
+	got := &Job{ID: j.ID}
+	_ = got.Get()
+	if got.Status != status.Cancelled {
+		t.Errorf("Job status = %v, want %v", got.Status, status.Cancelled)
+	}
+	if got.Output == "" {
+		t.Errorf("Job output is empty, want the partial output")
+	}
+
+	if _, ok := Cancel(j.ID, ErrCancelled); ok {
+		t.Errorf("Cancel() of a finished job = true, want false")
+	}
+}
+
+func TestJob_CancelOnSlurmlTimeout(t *testing.T) {
+	// A slurml API that never answers
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	defer func(url, token string, timeout time.Duration) {
+		SLURML_API_URL, SLURML_API_TOKEN, SlurmlCancelTimeout = url, token, timeout
+	}(SLURML_API_URL, SLURML_API_TOKEN, SlurmlCancelTimeout)
+	SLURML_API_URL, SLURML_API_TOKEN, SlurmlCancelTimeout = server.URL, "token", 100*time.Millisecond
+
+	j := &Job{ID: "TestJob_CancelOnSlurmlTimeout", Slurml: true, SlurmID: 42}
+
+	start := time.Now()
+	if err := j.CancelOnSlurml(); err == nil {
+		t.Errorf("Job.CancelOnSlurml() error = nil, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Job.CancelOnSlurml() took %v", elapsed)
+	}
+}
+
+func TestJob_RunLimits(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-limits"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	d1 := []byte("#!/bin/bash\nhead -c 3000000 /dev/zero > big")
+	err := os.WriteFile(testDir+"/run.sh", d1, 0775)
+	if err != nil {
+		t.Errorf("Job.Run() error = %v", err)
+	}
+
+	j := &Job{ID: "TestJob_RunLimits", Path: testDir, Limits: utils.Limits{FileSizeMB: 1}}
+	testutil.WriteRecord(t, j.ID, j)
+	if got := j.Run(); got != status.Failed {
+		t.Errorf("Job.Run() = %v, want %v", got, status.Failed)
+	}
+	if j.Message != "killed: file size limit exceeded" {
+		t.Errorf("Job.Run() message = %v", j.Message)
+	}
+}
+
+func TestJob_ExecuteInputFrom(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	sources := []Job{
+		{
+			ID:     "TestJob_ExecuteInputFrom-docking",
+			Status: status.Success,
+			Output: testutil.ZipBase64(t, map[string]string{"run.sh": "#!/bin/bash\ngrep -qx docked data.txt", "data.txt": "docked"}),
+		},
+		{ID: "TestJob_ExecuteInputFrom-failed", Status: status.Failed},
+	}
+	for _, s := range sources {
+		testutil.WriteRecord(t, s.ID, &s)
+	}
+
+	tests := []struct {
+		name        string
+		inputFrom   string
+		files       map[string]string
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			name:       "output of the source",
+			inputFrom:  "TestJob_ExecuteInputFrom-docking",
+			wantStatus: status.Success,
+		},
+		{
+			name:       "input replaces a file of the output",
+			inputFrom:  "TestJob_ExecuteInputFrom-docking",
+			files:      map[string]string{"data.txt": "empty"},
+			wantStatus: status.Failed,
+		},
+		{
+			name:       "input adds to the output",
+			inputFrom:  "TestJob_ExecuteInputFrom-docking",
+			files:      map[string]string{"run.sh": "#!/bin/bash\ntest -f data.txt"},
+			wantStatus: status.Success,
+		},
+		{
+			name:        "failed source",
+			inputFrom:   "TestJob_ExecuteInputFrom-failed",
+			wantStatus:  status.Failed,
+			wantMessage: "did not succeed",
+		},
+		{
+			name:        "missing source",
+			inputFrom:   "TestJob_ExecuteInputFrom-missing",
+			wantStatus:  status.Failed,
+			wantMessage: "not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := "./test-execute-input-from"
+			defer os.RemoveAll(testDir)
+
+			j := &Job{
+				ID:        "TestJob_ExecuteInputFrom-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Path:      testDir,
+				InputFrom: tt.inputFrom,
+			}
+			if tt.files != nil {
+				j.Input = testutil.ZipBase64(t, tt.files)
+			}
+			testutil.WriteRecord(t, j.ID, j)
+			_ = j.Execute()
+
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != tt.wantStatus {
+				t.Errorf("Job status = %v, want %v (%v)", got.Status, tt.wantStatus, got.Message)
+			}
+			if !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("Job message = %v, want it to contain %v", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"errors"
+	"io/fs"
+	"jobd/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+)
+
+// BaseEnv are the variables of jobd passed on to every job, anything else is
+// only given to the jobs through JOB_ENV
+var BaseEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+// defaultPath is the PATH of the jobs when jobd has none
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// secretEnv are the variables of jobd that never reach a job
+var secretEnv = []string{"SLURML_API_TOKEN", "SLURML_API_URL", "ADMIN_TOKEN"}
+
+// SLURML_API_URL and SLURML_API_TOKEN locate and authenticate the slurml API,
+// they are read before HideSecrets removes them from the environment
+var (
+	SLURML_API_URL   = os.Getenv("SLURML_API_URL")
+	SLURML_API_TOKEN = os.Getenv("SLURML_API_TOKEN")
+)
+
+// JobUser is the user and group the jobs run as, read from JOB_UID and JOB_GID
+// (the uid if unset); jobd must run as root to switch to them. When unset the
+// jobs run as jobd
+var JobUser = jobUser(utils.GetEnvInt64("JOB_UID", -1), utils.GetEnvInt64("JOB_GID", -1))
+
+// jobUser returns the credential of the jobs, nil to run them as jobd
+func jobUser(uid, gid int64) *syscall.Credential {
+	if uid < 0 {
+		return nil
+	}
+	if gid < 0 {
+		gid = uid
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+}
+
+// HideSecrets keeps the secrets of jobd from the jobs once every package read
+// them: they are removed from its environment and its /proc entries, where the
+// initial environment stays, are made unreadable to other processes of its user.
+// Jobs running as root can still read them, JOB_UID runs them as another user
+func HideSecrets() {
+	for _, k := range secretEnv {
+		_ = os.Unsetenv(k)
+	}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0)
+	if errno != 0 {
+		glog.Warning("could not protect the /proc entries of jobd: ", errno)
+	}
+
+	if JobUser == nil && os.Geteuid() == 0 {
+		glog.Warning("JOB_UID not set, the jobs run as root and can read the secrets of jobd")
+	}
+}
+
+// handOver gives the job directory, and the files the job writes outside of
+// it, to the user the job runs as
+func (j *Job) handOver(files ...string) {
+	if JobUser == nil {
+		return
+	}
+	uid, gid := int(JobUser.Uid), int(JobUser.Gid)
+
+	_ = filepath.WalkDir(j.Path, func(path string, d fs.DirEntry, err error) error {
+		if err == nil {
+			_ = os.Lchown(path, uid, gid)
+		}
+		return nil
+	})
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			continue
+		}
+		f.Close()
+		_ = os.Chown(file, uid, gid)
+	}
+}
+
+// JobEnv are the variables configured by the server for every job, read from
+// JOB_ENV as a comma separated list of `KEY=value`, or `KEY` to pass on the
+// value jobd has
+var JobEnv = parseJobEnv(os.Getenv("JOB_ENV"))
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseJobEnv reads the server configured variables
+func parseJobEnv(s string) map[string]string {
+	env := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, v, found := strings.Cut(entry, "=")
+		if !found {
+			v, found = os.LookupEnv(k)
+			if !found {
+				continue
+			}
+		}
+		if err := checkEnvName(k); err != nil {
+			glog.Warning("ignoring JOB_ENV entry: ", err)
+			continue
+		}
+		env[k] = v
+	}
+	return env
+}
+
+// checkEnvName makes sure a job can set the variable `k`
+func checkEnvName(k string) error {
+	if !envName.MatchString(k) {
+		return errors.New("invalid environment variable name " + k)
+	}
+	for _, secret := range secretEnv {
+		if k == secret {
+			return errors.New("environment variable " + k + " is reserved")
+		}
+	}
+	return nil
+}
+
+// checkEnv makes sure a job can set all the variables of `env`
+func checkEnv(env map[string]string) error {
+	for k := range env {
+		if err := checkEnvName(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// environ returns the environment of the job process, the allowlisted variables
+// of jobd extended, in order, by the server, the manifest and the upload
+func (j *Job) environ() []string {
+	env := map[string]string{"PATH": defaultPath}
+	for _, k := range BaseEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env[k] = v
+		}
+	}
+
+	layers := []map[string]string{JobEnv, j.Env}
+	if j.Manifest != nil {
+		layers = []map[string]string{JobEnv, j.Manifest.Env, j.Env}
+	}
+	for _, layer := range layers {
+		for k, v := range layer {
+			env[k] = v
+		}
+	}
+
+	// Whatever was configured, secrets are not handed out
+	for _, k := range secretEnv {
+		delete(env, k)
+	}
+
+	environ := make([]string, 0, len(env))
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+	return environ
+}
@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"jobd/utils/testutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"jobd/domain/status"
+)
+
+func TestParseJobEnv(t *testing.T) {
+	t.Setenv("JOBD_TEST_PASSED", "from-jobd")
+
+	tests := []struct {
+		name string
+		s    string
+		want map[string]string
+	}{
+		{
+			name: "empty",
+			s:    "",
+			want: map[string]string{},
+		},
+		{
+			name: "values and passed on variables",
+			s:    "A=1, B=x=y,JOBD_TEST_PASSED,JOBD_TEST_UNSET",
+			want: map[string]string{"A": "1", "B": "x=y", "JOBD_TEST_PASSED": "from-jobd"},
+		},
+		{
+			name: "secrets and invalid names are ignored",
+			s:    "SLURML_API_TOKEN=abc,1A=2,C=3",
+			want: map[string]string{"C": "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseJobEnv(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJobEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_environ(t *testing.T) {
+	t.Setenv("SLURML_API_TOKEN", "secret")
+	t.Setenv("JOBD_TEST_UNLISTED", "jobd")
+	t.Setenv("LANG", "C.UTF-8")
+
+	defer func(env map[string]string) { JobEnv = env }(JobEnv)
+	JobEnv = map[string]string{"SERVER": "server", "LAYER": "server"}
+
+	j := &Job{
+		Manifest: &Manifest{Env: map[string]string{"MANIFEST": "manifest", "LAYER": "manifest"}},
+		Env:      map[string]string{"UPLOAD": "upload", "LAYER": "upload"},
+	}
+
+	env := map[string]string{}
+	for _, kv := range j.environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+
+	for k, want := range map[string]string{
+		"LANG":     "C.UTF-8",
+		"SERVER":   "server",
+		"MANIFEST": "manifest",
+		"UPLOAD":   "upload",
+		"LAYER":    "upload",
+	} {
+		if env[k] != want {
+			t.Errorf("Job.environ() %v = %q, want %q", k, env[k], want)
+		}
+	}
+	if env["PATH"] == "" {
+		t.Errorf("Job.environ() has no PATH")
+	}
+	for _, k := range []string{"SLURML_API_TOKEN", "JOBD_TEST_UNLISTED"} {
+		if _, ok := env[k]; ok {
+			t.Errorf("Job.environ() leaks %v", k)
+		}
+	}
+}
+
+func TestJob_RunEnv(t *testing.T) {
+	t.Setenv("SLURML_API_TOKEN", "secret")
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-env"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	d1 := []byte("#!/bin/bash\nset -u\necho \"$GREETING\"\n[ -z \"${SLURML_API_TOKEN:-}\" ]")
+	err := os.WriteFile(testDir+"/run.sh", d1, 0775)
+	if err != nil {
+		t.Errorf("Job.Run() error = %v", err)
+	}
+
+	j := &Job{ID: "TestJob_RunEnv", Path: testDir, Env: map[string]string{"GREETING": "hi"}}
+	testutil.WriteRecord(t, j.ID, j)
+	if got := j.Run(); got != status.Success {
+		t.Errorf("Job.Run() = %v, want %v (%v)", got, status.Success, j.Message)
+	}
+}
+
+func TestJob_RunSecrets(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("only root can run the jobs as another user")
+	}
+	defer func(u *syscall.Credential) { JobUser = u }(JobUser)
+	JobUser = jobUser(65534, -1)
+
+	testutil.CleanupDB(t)
+
+	// The job user must be able to get to its directories
+	testDir, _ := os.MkdirTemp("", "jobd-test-run-secrets")
+	defer os.RemoveAll(testDir)
+	logDir := testDir + "-logs"
+	defer os.RemoveAll(logDir)
+
+	// The job reads the environment of jobd, where the secrets were
+	script := "#!/bin/bash\n" +
+		"[ \"$(id -u)\" = 65534 ] || exit 2\n" +
+		"echo 50 > \"$JOBD_PROGRESS\" || exit 3\n" +
+		"! cat /proc/" + strconv.Itoa(os.Getpid()) + "/environ > /dev/null 2>&1\n"
+	_ = os.WriteFile(testDir+"/run.sh", []byte(script), 0700)
+
+	j := &Job{ID: "TestJob_RunSecrets", Path: testDir, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.WriteRecord(t, j.ID, j)
+	if got := j.Run(); got != status.Success {
+		t.Errorf("Job.Run() = %v, want %v (%v)", got, status.Success, j.Message)
+	}
+}
+
+func TestHideSecrets(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+	t.Setenv("LANG", "C.UTF-8")
+
+	HideSecrets()
+
+	if _, ok := os.LookupEnv("ADMIN_TOKEN"); ok {
+		t.Errorf("HideSecrets() left ADMIN_TOKEN in the environment")
+	}
+	if _, ok := os.LookupEnv("LANG"); !ok {
+		t.Errorf("HideSecrets() removed LANG from the environment")
+	}
+}
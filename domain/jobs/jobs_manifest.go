@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFiles are the names a manifest can have inside the input, in order of precedence
+var ManifestFiles = []string{"jobd.json", "jobd.yaml", "jobd.yml"}
+
+// Manifest describes how to run a job, it replaces the default `./run.sh`
+type Manifest struct {
+	// Command is the program to run, relative to the job directory or in the PATH
+	Command string `json:"command" yaml:"command"`
+	// Args are given to the command
+	Args []string `json:"args" yaml:"args"`
+	// Interpreter runs the command, e.g. `python3`
+	Interpreter string `json:"interpreter" yaml:"interpreter"`
+	// Env are extra environment variables of the job
+	Env map[string]string `json:"env" yaml:"env"`
+	// Outputs are glob patterns of the files the job must produce
+	Outputs []string `json:"outputs" yaml:"outputs"`
+	// Timeout is the wall-clock limit of the job in seconds, used when the upload has none
+	Timeout int `json:"timeout" yaml:"timeout"`
+}
+
+// parseManifest decodes a manifest, `name` tells its format
+func parseManifest(name string, b []byte) (*Manifest, error) {
+	m := &Manifest{}
+
+	var err error
+	if filepath.Ext(name) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(m)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(m)
+	}
+	if err != nil {
+		return nil, errors.New("invalid " + name + ": " + err.Error())
+	}
+
+	if m.Timeout < 0 {
+		return nil, errors.New("invalid " + name + ": timeout must be a positive number of seconds")
+	}
+
+	if err := checkEnv(m.Env); err != nil {
+		return nil, errors.New("invalid " + name + ": " + err.Error())
+	}
+
+	if m.Command == "" {
+		m.Command = "run.sh"
+	}
+
+	return m, nil
+}
+
+// ReadManifest looks for a manifest at the root of a base64 encoded zip,
+// returns nil if there is none. An input that cannot be read is left for
+// Prepare to report
+func ReadManifest(input string) (*Manifest, error) {
+	b, err := base64.StdEncoding.DecodeString(input)
+	if err != nil {
+		return nil, nil
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, name := range ManifestFiles {
+		for _, file := range reader.File {
+			if file.Name != name {
+				continue
+			}
+			f, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			content, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			return parseManifest(name, content)
+		}
+	}
+
+	return nil, nil
+}
+
+// LoadManifest looks for a manifest in the job directory, returns nil if there is none
+func LoadManifest(dir string) (*Manifest, error) {
+	for _, name := range ManifestFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseManifest(name, content)
+	}
+
+	return nil, nil
+}
+
+// command returns the program and arguments that run the job
+func (j *Job) command() (string, []string) {
+	if j.Manifest == nil {
+		return "./run.sh", nil
+	}
+
+	command := j.Manifest.Command
+	if j.isLocalFile(command) {
+		command = "./" + filepath.Clean(command)
+	}
+
+	if j.Manifest.Interpreter != "" {
+		return j.Manifest.Interpreter, append([]string{command}, j.Manifest.Args...)
+	}
+
+	return command, j.Manifest.Args
+}
+
+// isLocalFile reports if `name` is a file inside the job directory
+func (j *Job) isLocalFile(name string) bool {
+	if filepath.IsAbs(name) || !filepath.IsLocal(name) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(j.Path, name))
+	return err == nil && !info.IsDir()
+}
+
+// checkCommand makes sure the command of the manifest can be executed
+func (j *Job) checkCommand() error {
+	m := j.Manifest
+
+	if m.Interpreter != "" {
+		if _, err := exec.LookPath(m.Interpreter); err != nil {
+			return errors.New("interpreter " + m.Interpreter + " not found")
+		}
+		if !j.isLocalFile(m.Command) {
+			return errors.New(m.Command + " does not exist in the input file")
+		}
+		return nil
+	}
+
+	if j.isLocalFile(m.Command) {
+		return os.Chmod(filepath.Join(j.Path, m.Command), 0775)
+	}
+
+	if _, err := exec.LookPath(m.Command); err != nil {
+		return errors.New("command " + m.Command + " not found")
+	}
+
+	return nil
+}
+
+// missingOutputs returns the patterns of the manifest that match no file
+func (j *Job) missingOutputs() []string {
+	if j.Manifest == nil {
+		return nil
+	}
+
+	missing := []string{}
+	for _, pattern := range j.Manifest.Outputs {
+		matches, _ := filepath.Glob(filepath.Join(j.Path, pattern))
+		if len(matches) == 0 {
+			missing = append(missing, pattern)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"jobd/utils/testutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jobd/domain/status"
+)
+
+func TestReadManifest(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    *Manifest
+		wantErr bool
+	}{
+		{
+			name:  "no manifest",
+			files: map[string]string{"run.sh": "#!/bin/bash"},
+			want:  nil,
+		},
+		{
+			name:  "json manifest",
+			files: map[string]string{"jobd.json": `{"command": "main.py", "args": ["-v"], "interpreter": "python3", "env": {"A": "1"}, "outputs": ["*.txt"], "timeout": 30}`},
+			want: &Manifest{
+				Command:     "main.py",
+				Args:        []string{"-v"},
+				Interpreter: "python3",
+				Env:         map[string]string{"A": "1"},
+				Outputs:     []string{"*.txt"},
+				Timeout:     30,
+			},
+		},
+		{
+			name:  "yaml manifest without command",
+			files: map[string]string{"jobd.yaml": "args: [a, b]\ntimeout: 5\n"},
+			want:  &Manifest{Command: "run.sh", Args: []string{"a", "b"}, Timeout: 5},
+		},
+		{
+			name:    "unknown field",
+			files:   map[string]string{"jobd.json": `{"cmd": "main.py"}`},
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			files:   map[string]string{"jobd.yml": "timeout: -1\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadManifest(testutil.ZipBase64(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadManifest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_ExecuteManifest(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			name: "command with arguments and env",
+			files: map[string]string{
+				"jobd.json": `{"command": "main.sh", "args": ["out.txt"], "interpreter": "bash", "env": {"GREETING": "hi"}, "outputs": ["out.txt"]}`,
+				"main.sh":   "echo $GREETING > $1",
+			},
+			wantStatus: status.Success,
+		},
+		{
+			name: "command in the path",
+			files: map[string]string{
+				"jobd.yaml": "command: touch\nargs: [done.txt]\noutputs: [done.txt]\n",
+			},
+			wantStatus: status.Success,
+		},
+		{
+			name: "missing output",
+			files: map[string]string{
+				"jobd.yaml": "outputs: [result.csv]\n",
+				"run.sh":    "#!/bin/bash\necho no result",
+			},
+			wantStatus:  status.Failed,
+			wantMessage: "result.csv",
+		},
+		{
+			name: "missing command",
+			files: map[string]string{
+				"jobd.json": `{"command": "main.py", "interpreter": "python3"}`,
+			},
+			wantStatus:  status.Failed,
+			wantMessage: "main.py",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := "./test-execute-manifest"
+			defer os.RemoveAll(testDir)
+
+			j := &Job{
+				ID:    "TestJob_ExecuteManifest-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Path:  testDir,
+				Input: testutil.ZipBase64(t, tt.files),
+			}
+			testutil.WriteRecord(t, j.ID, j)
+			_ = j.Execute()
+
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != tt.wantStatus {
+				t.Errorf("Job status = %v, want %v (%v)", got.Status, tt.wantStatus, got.Message)
+			}
+			if !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("Job message = %v, want it to contain %v", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"jobd/domain/status"
+	"jobd/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OutputSelection chooses the files of the job directory that go in the output:
+// the ones matching `include` (all of them if empty) and none of `exclude`. The
+// patterns are globs matched against the path relative to the job directory, or
+// any of its parent directories; patterns without a `/` also match base names.
+// `exclude_inputs` leaves out the input files the job did not modify
+type OutputSelection struct {
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	ExcludeInputs bool     `json:"exclude_inputs"`
+}
+
+// OutputFile describes a file of the output of a job, so clients know what the
+// job produced without unzipping it
+type OutputFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	SHA256   string    `json:"sha256"`
+}
+
+// fileState is what tells if a file was modified
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// IsZero reports if the selection keeps the whole job directory
+func (s OutputSelection) IsZero() bool {
+	return len(s.Include) == 0 && len(s.Exclude) == 0 && !s.ExcludeInputs
+}
+
+// Validate checks the patterns of the selection
+func (s OutputSelection) Validate() error {
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.New("invalid output pattern " + pattern)
+		}
+	}
+	return nil
+}
+
+// selects reports if the file `name`, relative to the job directory, matches
+// the patterns of the selection
+func (s OutputSelection) selects(name string) bool {
+	included := len(s.Include) == 0
+	for _, pattern := range s.Include {
+		if matchPath(pattern, name) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, pattern := range s.Exclude {
+		if matchPath(pattern, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath reports if `pattern` matches the relative path `name` or one of its
+// parent directories, by their full path or, for patterns without a `/`, their
+// base name
+func matchPath(pattern, name string) bool {
+	baseOnly := !strings.Contains(pattern, "/")
+	for p := filepath.Clean(name); p != "." && p != "/"; p = filepath.Dir(p) {
+		if ok, _ := filepath.Match(pattern, p); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(p)); baseOnly && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordInputs remembers the state of the input files, once they are unpacked,
+// to leave out of the output the ones the job does not modify
+func (j *Job) recordInputs() {
+	j.inputs = map[string]fileState{}
+	_ = filepath.WalkDir(j.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(j.Path, path)
+		if err != nil {
+			return nil
+		}
+		j.inputs[rel] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+}
+
+// unchangedInput reports if the file `name` is an input file the job did not modify
+func (j *Job) unchangedInput(name string) bool {
+	state, ok := j.inputs[name]
+	if !ok {
+		return false
+	}
+	info, err := os.Lstat(filepath.Join(j.Path, name))
+	if err != nil {
+		return false
+	}
+	return info.Size() == state.size && info.ModTime().Equal(state.modTime)
+}
+
+// outputFiles returns the files of the job directory selected for the output,
+// relative to it
+func (j *Job) outputFiles() []string {
+	files := []string{}
+	_ = filepath.WalkDir(j.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(j.Path, path)
+		if err != nil {
+			return nil
+		}
+		if !j.OutputSelection.selects(rel) {
+			return nil
+		}
+		if j.OutputSelection.ExcludeInputs && j.unchangedInput(rel) {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files
+}
+
+// zipOutput compresses the files of the job directory selected for the output
+func (j *Job) zipOutput() ([]byte, error) {
+	if j.OutputSelection.IsZero() {
+		return utils.Zip(j.Path)
+	}
+	if _, err := os.Stat(j.Path); err != nil {
+		return nil, err
+	}
+	return utils.ZipFiles(j.Path, j.outputFiles())
+}
+
+// outputManifest lists the files of the zipped output `bArr`, sorted by path,
+// with the modification time they have in the job directory
+func (j *Job) outputManifest(bArr []byte) ([]OutputFile, error) {
+	r, err := zip.NewReader(bytes.NewReader(bArr), int64(len(bArr)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := []OutputFile{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// The archive does not keep the modification times
+		modified := f.Modified
+		if info, err := os.Stat(filepath.Join(j.Path, filepath.FromSlash(f.Name))); err == nil {
+			modified = info.ModTime()
+		}
+
+		files = append(files, OutputFile{
+			Path:     f.Name,
+			Size:     size,
+			Modified: modified,
+			SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+
+	sort.Slice(files, func(a, b int) bool { return files[a].Path < files[b].Path })
+	return files, nil
+}
+
+// OutputArchive and PartialArchive name the `.zip` files, in the log directory of
+// a job, that keep its output once it finished and the last snapshot of its
+// published results while it runs, so they are downloaded without going through
+// its record
+const (
+	OutputArchive  = "output"
+	PartialArchive = "partial"
+)
+
+// ArchiveInfo describes an archive, it is saved next to it
+type ArchiveInfo struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	SHA256 string `json:"sha256"`
+}
+
+// Archive is an open archive of a job
+type Archive struct {
+	*os.File
+	ArchiveInfo
+	Modified time.Time
+}
+
+// OpenArchive opens the archive `name` kept in the log directory `logPath`
+func OpenArchive(logPath, name string) (*Archive, error) {
+	if logPath == "" {
+		return nil, os.ErrNotExist
+	}
+
+	bInfo, err := os.ReadFile(filepath.Join(logPath, name+".json"))
+	if err != nil {
+		return nil, err
+	}
+	info := ArchiveInfo{}
+	if err := json.Unmarshal(bInfo, &info); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(logPath, name+".zip"))
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Archive{File: f, ArchiveInfo: info, Modified: stat.ModTime()}, nil
+}
+
+// saveArchive keeps `bArr` as the archive `name` of the job, the files are
+// replaced at once so a download never sees them halfway
+func (j *Job) saveArchive(name string, bArr []byte) error {
+	if j.LogPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(j.LogPath, 0755); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(bArr)
+	bInfo, err := json.Marshal(ArchiveInfo{ID: j.ID, Status: j.Status, SHA256: hex.EncodeToString(sum[:])})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(j.LogPath, name+".zip"), bArr); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(j.LogPath, name+".json"), bInfo)
+}
+
+// removeArchive deletes the archive `name` of the job
+func (j *Job) removeArchive(name string) {
+	if j.LogPath == "" {
+		return
+	}
+	_ = os.Remove(filepath.Join(j.LogPath, name+".json"))
+	_ = os.Remove(filepath.Join(j.LogPath, name+".zip"))
+}
+
+// ArchiveOutput saves the output of the record of the job as its archive, for
+// jobs finished remotely or before the archives were kept, and array jobs, then
+// opens it
+func (j *Job) ArchiveOutput() (*Archive, error) {
+	bArr, err := base64.StdEncoding.DecodeString(j.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	name := OutputArchive
+	if j.Status == status.Partial {
+		name = PartialArchive
+	}
+	if err := j.saveArchive(name, bArr); err != nil {
+		return nil, err
+	}
+	return OpenArchive(j.LogPath, name)
+}
+
+// writeFileAtomic writes a file through a temporary one renamed over it
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	_ = os.Chmod(tmp.Name(), 0644)
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"io/fs"
+	"jobd/domain/status"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputSelection_selects(t *testing.T) {
+	tests := []struct {
+		name string
+		s    OutputSelection
+		file string
+		want bool
+	}{
+		{name: "no patterns", s: OutputSelection{}, file: "models/model_1.pdb", want: true},
+		{name: "included base name", s: OutputSelection{Include: []string{"*.pdb"}}, file: "models/model_1.pdb", want: true},
+		{name: "not included", s: OutputSelection{Include: []string{"*.pdb"}}, file: "scores.csv", want: false},
+		{name: "included directory", s: OutputSelection{Include: []string{"models"}}, file: "models/model_1.pdb", want: true},
+		{name: "included path", s: OutputSelection{Include: []string{"models/*_1.pdb"}}, file: "models/model_1.pdb", want: true},
+		{name: "path pattern on other directory", s: OutputSelection{Include: []string{"models/*.pdb"}}, file: "other/models/model_1.pdb", want: false},
+		{name: "excluded", s: OutputSelection{Exclude: []string{"*.tmp"}}, file: "run/scratch.tmp", want: false},
+		{name: "excluded directory", s: OutputSelection{Exclude: []string{"scratch"}}, file: "run/scratch/data", want: false},
+		{name: "included and excluded", s: OutputSelection{Include: []string{"models"}, Exclude: []string{"*_2.pdb"}}, file: "models/model_2.pdb", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.selects(tt.file); got != tt.want {
+				t.Errorf("OutputSelection.selects(%v) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_RunOutputSelection(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	files := map[string]string{
+		"run.sh":     "#!/bin/bash\nmkdir -p models scratch\necho 1 > models/model_1.pdb\necho tmp > scratch/data\necho changed >> params.txt",
+		"params.txt": "params",
+		"input.dat":  "input",
+	}
+
+	tests := []struct {
+		name string
+		s    OutputSelection
+		want []string
+	}{
+		{
+			name: "whole directory",
+			s:    OutputSelection{},
+			want: []string{"input.dat", "models/model_1.pdb", "params.txt", "run.sh", "scratch/data"},
+		},
+		{
+			name: "new and modified files",
+			s:    OutputSelection{Exclude: []string{"scratch"}, ExcludeInputs: true},
+			want: []string{"models/model_1.pdb", "params.txt"},
+		},
+		{
+			name: "included files",
+			s:    OutputSelection{Include: []string{"*.pdb", "*.dat"}, ExcludeInputs: true},
+			want: []string{"models/model_1.pdb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := "./test-run-output-selection"
+			defer os.RemoveAll(testDir)
+
+			j := &Job{
+				ID:              "TestJob_RunOutputSelection-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Path:            testDir,
+				Input:           testutil.ZipBase64(t, files),
+				OutputSelection: tt.s,
+			}
+			_ = j.Execute()
+
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != status.Success {
+				t.Fatalf("Job status = %v, want %v (%v)", got.Status, status.Success, got.Message)
+			}
+
+			outputDir := "./test-run-output-selection-output"
+			defer os.RemoveAll(outputDir)
+			_ = utils.Unzip(got.Output, outputDir)
+			names := []string{}
+			_ = filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(outputDir, path)
+					names = append(names, rel)
+				}
+				return nil
+			})
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("output = %v, want %v", names, tt.want)
+			}
+
+			paths := []string{}
+			for _, f := range got.OutputManifest {
+				paths = append(paths, f.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("output manifest = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_outputManifest(t *testing.T) {
+	testDir := "./test-output-manifest"
+	_ = os.MkdirAll(testDir+"/models", 0755)
+	defer os.RemoveAll(testDir)
+
+	_ = os.WriteFile(testDir+"/scores.csv", []byte("scores"), 0644)
+	_ = os.WriteFile(testDir+"/models/model_1.pdb", []byte("model"), 0644)
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	_ = os.Chtimes(testDir+"/scores.csv", modified, modified)
+
+	bArr, err := utils.Zip(testDir)
+	if err != nil {
+		t.Fatalf("Zip() error = %v", err)
+	}
+
+	j := &Job{Path: testDir}
+	got, err := j.outputManifest(bArr)
+	if err != nil {
+		t.Fatalf("Job.outputManifest() error = %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("Job.outputManifest() = %v, want 2 files", got)
+	}
+	want := OutputFile{
+		Path:     "scores.csv",
+		Size:     6,
+		Modified: modified,
+		// sha256sum of "scores"
+		SHA256: "a6235752ef51e86cdf1d56634948c32f75fe6a492e212797315ea43f5ecfa66e",
+	}
+	if got[0].Path != "models/model_1.pdb" {
+		t.Errorf("Job.outputManifest() first file = %v, want models/model_1.pdb", got[0].Path)
+	}
+	if got[1].Path != want.Path || got[1].Size != want.Size || !got[1].Modified.Equal(want.Modified) || got[1].SHA256 != want.SHA256 {
+		t.Errorf("Job.outputManifest() = %+v, want %+v", got[1], want)
+	}
+
+	if _, err := j.outputManifest(nil); err == nil {
+		t.Errorf("Job.outputManifest() of no output, want an error")
+	}
+}
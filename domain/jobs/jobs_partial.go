@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"encoding/base64"
+	"io/fs"
+	"jobd/domain/status"
+	"jobd/errors"
+	"jobd/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// PartialInterval is how often the results published by a running job are
+// snapshotted into its partial output, 0 disables the snapshots
+var PartialInterval = time.Duration(utils.GetEnvInt64("JOB_PARTIAL_INTERVAL", 30)) * time.Second
+
+// publishable returns the files of the job directory that can be shown before
+// the job finishes: the outputs declared by its manifest and the ones matching
+// the patterns it published, relative to the job directory
+func (j *Job) publishable(published []string) []string {
+	patterns := slices.Clone(published)
+	if j.Manifest != nil {
+		patterns = append(patterns, j.Manifest.Outputs...)
+	}
+
+	seen := map[string]bool{}
+	files := []string{}
+	for _, pattern := range patterns {
+		// Nothing outside of the job directory is published
+		if !filepath.IsLocal(pattern) {
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(j.Path, pattern))
+		for _, match := range matches {
+			_ = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				// Symbolic links could lead out of the job directory
+				if err != nil || !d.Type().IsRegular() {
+					return nil
+				}
+				rel, err := filepath.Rel(j.Path, path)
+				if err != nil || !filepath.IsLocal(rel) || seen[rel] {
+					return nil
+				}
+				seen[rel] = true
+				files = append(files, rel)
+				return nil
+			})
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// filesSignature identifies the state of the files, it changes when any of them does
+func filesSignature(dir string, files []string) string {
+	var b strings.Builder
+	for _, name := range files {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		b.WriteString(name + ":" + strconv.FormatInt(info.Size(), 10) + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n")
+	}
+	return b.String()
+}
+
+// snapshotPartials saves the results published by the running job as its
+// partial output every PartialInterval; the returned function stops it
+func (j *Job) snapshotPartials(progress *progressTracker) func() {
+	if PartialInterval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(PartialInterval)
+		defer ticker.Stop()
+
+		var last string
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			last = j.savePartial(progress.publishedPatterns(), last)
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// savePartial zips the published results into the partial archive of the job,
+// now PARTIAL, unless they did not change since the snapshot with signature `last`.
+// Returns the signature of the results saved
+func (j *Job) savePartial(published []string, last string) string {
+	files := j.publishable(published)
+	if len(files) == 0 {
+		return last
+	}
+
+	signature := filesSignature(j.Path, files)
+	if signature == last {
+		return last
+	}
+
+	bArr, err := utils.ZipFiles(j.Path, files)
+	if err != nil {
+		glog.Warning("could not snapshot the results of ", j.ID, ": ", err)
+		return last
+	}
+	manifest, _ := j.outputManifest(bArr)
+
+	// The snapshot is there once the job is PARTIAL
+	partial := &Job{ID: j.ID, LogPath: j.LogPath, Status: status.Partial}
+	if err := partial.saveArchive(PartialArchive, bArr); err != nil {
+		glog.Warning("could not archive the results of ", j.ID, ": ", err)
+	}
+
+	errUpdate := partial.Update(func(p *Job) *errors.RestErr {
+		if p.Status != status.Running && p.Status != status.Partial {
+			return errors.NewConflictError("job is not running")
+		}
+		p.OutputManifest = manifest
+		p.Status = status.Partial
+		return nil
+	})
+	if errUpdate != nil {
+		partial.removeArchive(PartialArchive)
+	}
+
+	return signature
+}
+
+// PartialOutput returns the last snapshot of the results published by the job,
+// base64 encoded like its output; it is kept out of the record
+func (j *Job) PartialOutput() string {
+	bArr, err := os.ReadFile(filepath.Join(j.LogPath, PartialArchive+".zip"))
+	if err != nil || j.LogPath == "" {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(bArr)
+}
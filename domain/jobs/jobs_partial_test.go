@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"jobd/domain/status"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJob_publishable(t *testing.T) {
+	testDir := "./test-publishable"
+	_ = os.MkdirAll(testDir+"/models", 0755)
+	defer os.RemoveAll(testDir)
+
+	for _, name := range []string{"run.sh", "scores.csv", "models/model_1.pdb", "models/model_2.pdb"} {
+		_ = os.WriteFile(testDir+"/"+name, []byte(name), 0644)
+	}
+	_ = os.Symlink("/etc/hostname", testDir+"/models/link.pdb")
+
+	j := &Job{Path: testDir, Manifest: &Manifest{Outputs: []string{"scores.csv"}}}
+
+	tests := []struct {
+		name      string
+		published []string
+		want      []string
+	}{
+		{name: "declared outputs", published: nil, want: []string{"scores.csv"}},
+		{name: "published directory", published: []string{"models"}, want: []string{"models/model_1.pdb", "models/model_2.pdb", "scores.csv"}},
+		{name: "published pattern", published: []string{"models/*_1.pdb"}, want: []string{"models/model_1.pdb", "scores.csv"}},
+		{name: "outside of the job", published: []string{"../*"}, want: []string{"scores.csv"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := j.publishable(tt.published); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Job.publishable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_RunPartial(t *testing.T) {
+	defer func(interval time.Duration) { PartialInterval = interval }(PartialInterval)
+	PartialInterval = 10 * time.Millisecond
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-partial"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	// Publishes a first result and waits until jobd snapshotted it
+	script := "#!/bin/bash\n" +
+		"echo first > result_1.txt\n" +
+		"echo '::jobd publish=result_*.txt'\n" +
+		"while [ ! -f proceed ]; do sleep 0.01; done\n" +
+		"echo second > result_2.txt\n"
+	_ = os.WriteFile(testDir+"/run.sh", []byte(script), 0775)
+
+	logDir := "./test-run-partial-logs"
+	defer os.RemoveAll(logDir)
+
+	j := &Job{ID: "TestJob_RunPartial", Path: testDir, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- j.Run()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	got := &Job{ID: j.ID}
+	for {
+		_ = got.Get()
+		if got.Status == status.Partial {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job status = %v, want %v", got.Status, status.Partial)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Only the published results are in the partial output
+	partialDir := "./test-run-partial-output"
+	defer os.RemoveAll(partialDir)
+	if got.Output != "" {
+		t.Errorf("Job output = %v, want the snapshot kept out of the record", got.Output)
+	}
+	_ = utils.Unzip(got.PartialOutput(), partialDir)
+	entries, _ := os.ReadDir(partialDir)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"result_1.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("partial output = %v, want %v", names, want)
+	}
+
+	// It is downloaded from its archive
+	archive, err := OpenArchive(logDir, PartialArchive)
+	if err != nil {
+		t.Fatalf("OpenArchive() error = %v", err)
+	}
+	archive.Close()
+	if archive.Status != status.Partial {
+		t.Errorf("partial archive status = %v, want %v", archive.Status, status.Partial)
+	}
+
+	_ = os.WriteFile(testDir+"/proceed", nil, 0644)
+	select {
+	case s := <-done:
+		if s != status.Success {
+			t.Errorf("Job.Run() = %v, want %v", s, status.Success)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Job.Run() did not return")
+	}
+
+	_ = got.Get()
+	if got.Status != status.Success {
+		t.Errorf("Job status = %v, want %v", got.Status, status.Success)
+	}
+
+	// The final output replaces the snapshot
+	if _, err := OpenArchive(logDir, PartialArchive); err == nil {
+		t.Errorf("OpenArchive() of the snapshot of a finished job, want an error")
+	}
+	archive, err = OpenArchive(logDir, OutputArchive)
+	if err != nil {
+		t.Fatalf("OpenArchive() error = %v", err)
+	}
+	defer archive.Close()
+	bArr, _ := io.ReadAll(archive)
+	sum := sha256.Sum256(bArr)
+	if archive.Status != status.Success || archive.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("output archive = %+v, want status %v and SHA-256 %x", archive.ArchiveInfo, status.Success, sum)
+	}
+	if base64.StdEncoding.EncodeToString(bArr) != got.Output {
+		t.Errorf("output archive differs from the output of the job")
+	}
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"jobd/domain/status"
+	"jobd/errors"
+	"jobd/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PostScript is the script of the input file run on the results of a job once
+// it succeeded
+const PostScript = "post.sh"
+
+// PostHook is a command, with its arguments, run on the results of every job
+// that succeeded, after the post.sh of the job, e.g. to convert them for the
+// application served
+var PostHook = os.Getenv("JOB_POST_HOOK")
+
+// postCommands returns the post-processing steps of the job, in order, each a
+// command followed by its arguments
+func (j *Job) postCommands() [][]string {
+	commands := [][]string{}
+	if j.isLocalFile(PostScript) {
+		_ = os.Chmod(filepath.Join(j.Path, PostScript), 0775)
+		commands = append(commands, []string{"./" + PostScript})
+	}
+	if hook := strings.Fields(PostHook); len(hook) > 0 {
+		commands = append(commands, hook)
+	}
+	return commands
+}
+
+// postProcess runs the post-processing steps of the job like its main command,
+// with the same environment, logs and limits, while it is POST_PROCESSING; it
+// stops at the first step that fails
+func (j *Job) postProcess(ctx context.Context, script utils.Script) error {
+	commands := j.postCommands()
+	if len(commands) == 0 {
+		return nil
+	}
+
+	// Only the status changes, the record holds the progress and partial output
+	record := &Job{ID: j.ID}
+	_ = record.Update(func(r *Job) *errors.RestErr {
+		r.Status = status.Post_Processing
+		return nil
+	})
+	j.Status = status.Post_Processing
+
+	for _, command := range commands {
+		script.Command, script.Args = command[0], command[1:]
+		err := script.Run(ctx)
+		j.PostProcess = script.Info
+		if err != nil {
+			// The step could not even start
+			if j.PostProcess == nil {
+				j.PostProcess = &utils.ProcessInfo{ExitCode: -1}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// postFailed reports if the post-processing of the job failed; it only runs
+// once the job succeeded so any failure after it started is its own
+func (j *Job) postFailed() bool {
+	return j.PostProcess != nil
+}
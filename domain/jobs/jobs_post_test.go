@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"jobd/domain/status"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJob_RunPost(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	hookDir := "/tmp/jobd-test-post-hook"
+	_ = os.MkdirAll(hookDir, 0755)
+	defer os.RemoveAll(hookDir)
+	_ = os.WriteFile(hookDir+"/hook.sh", []byte("#!/bin/bash\ntest -f post.txt && echo hooked > hook.txt"), 0755)
+	_ = os.WriteFile(hookDir+"/args.sh", []byte("#!/bin/bash\necho hooked > \"$1\""), 0755)
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		hook        string
+		timeout     int
+		wantStatus  string
+		wantMessage string
+		wantFiles   []string
+		wantMissing []string
+		wantClass   string
+	}{
+		{
+			name:       "without post-processing",
+			files:      map[string]string{"run.sh": "#!/bin/bash\nexit 0"},
+			wantStatus: status.Success,
+		},
+		{
+			name:       "post.sh and the server hook",
+			files:      map[string]string{"run.sh": "#!/bin/bash\necho raw > raw.txt", "post.sh": "#!/bin/bash\ncp raw.txt post.txt"},
+			hook:       hookDir + "/hook.sh",
+			wantStatus: status.Success,
+			wantFiles:  []string{"post.txt", "hook.txt"},
+		},
+		{
+			name:        "post.sh fails",
+			files:       map[string]string{"run.sh": "#!/bin/bash\nexit 0", "post.sh": "#!/bin/bash\nexit 4"},
+			wantStatus:  status.Failed,
+			wantMessage: "post-processing failed",
+			wantClass:   FailurePost,
+		},
+		{
+			name:       "server hook with arguments",
+			files:      map[string]string{"run.sh": "#!/bin/bash\nexit 0"},
+			hook:       hookDir + "/args.sh converted.txt",
+			wantStatus: status.Success,
+			wantFiles:  []string{"converted.txt"},
+		},
+		{
+			name:        "post.sh times out",
+			files:       map[string]string{"run.sh": "#!/bin/bash\nexit 0", "post.sh": "#!/bin/bash\nsleep 10"},
+			timeout:     1,
+			wantStatus:  status.Failed,
+			wantMessage: "post-processing failed",
+			wantClass:   FailurePost,
+		},
+		{
+			name: "outputs missing",
+			files: map[string]string{
+				"jobd.json": `{"command": "run.sh", "outputs": ["result.txt"]}`,
+				"run.sh":    "#!/bin/bash\nexit 0",
+				"post.sh":   "#!/bin/bash\necho ran > post.txt",
+			},
+			wantStatus:  status.Failed,
+			wantMessage: "expected outputs were not produced",
+			wantMissing: []string{"post.txt"},
+			wantClass:   FailureExit,
+		},
+		{
+			name:        "run.sh fails",
+			files:       map[string]string{"run.sh": "#!/bin/bash\nexit 1", "post.sh": "#!/bin/bash\necho ran > post.txt"},
+			wantStatus:  status.Failed,
+			wantMessage: "could not finish the job",
+			wantMissing: []string{"post.txt"},
+			wantClass:   FailureExit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(hook string) { PostHook = hook }(PostHook)
+			PostHook = tt.hook
+
+			testDir := "./test-run-post"
+			defer os.RemoveAll(testDir)
+
+			j := &Job{
+				ID:      "TestJob_RunPost-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Path:    testDir,
+				Input:   testutil.ZipBase64(t, tt.files),
+				Timeout: tt.timeout,
+			}
+			_ = j.Execute()
+
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != tt.wantStatus {
+				t.Errorf("Job status = %v, want %v (%v)", got.Status, tt.wantStatus, got.Message)
+			}
+			if !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("Job message = %v, want it to contain %v", got.Message, tt.wantMessage)
+			}
+			if class := got.Attempts[len(got.Attempts)-1].Failure; class != tt.wantClass {
+				t.Errorf("Attempt failure = %q, want %q", class, tt.wantClass)
+			}
+
+			outputDir := "./test-run-post-output"
+			defer os.RemoveAll(outputDir)
+			_ = utils.Unzip(got.Output, outputDir)
+			for _, name := range tt.wantFiles {
+				if _, err := os.Stat(outputDir + "/" + name); err != nil {
+					t.Errorf("%v is not in the output", name)
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if _, err := os.Stat(outputDir + "/" + name); err == nil {
+					t.Errorf("%v is in the output, the post-processing ran", name)
+				}
+			}
+		})
+	}
+}
+
+func TestJob_RunPostStatus(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-post-status"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	_ = os.WriteFile(testDir+"/run.sh", []byte("#!/bin/bash\nexit 0"), 0775)
+	_ = os.WriteFile(testDir+"/post.sh", []byte("#!/bin/bash\nwhile [ ! -f proceed ]; do sleep 0.01; done"), 0775)
+
+	j := &Job{ID: "TestJob_RunPostStatus", Path: testDir}
+	testutil.WriteRecord(t, j.ID, j)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- j.Run()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := &Job{ID: j.ID}
+		_ = got.Get()
+		if got.Status == status.Post_Processing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job status = %v, want %v", got.Status, status.Post_Processing)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = os.WriteFile(testDir+"/proceed", nil, 0644)
+	select {
+	case s := <-done:
+		if s != status.Success {
+			t.Errorf("Job.Run() = %v, want %v", s, status.Success)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Job.Run() did not return")
+	}
+}
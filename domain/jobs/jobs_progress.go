@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"bytes"
+	"jobd/errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressPollInterval is how often the progress of a running job is read and saved
+var ProgressPollInterval = time.Second
+
+// ProgressFile is the file, in the log directory, where a job can write its
+// progress; its path is given to the job in JOBD_PROGRESS
+const ProgressFile = "progress"
+
+// progressPrefix starts the lines of the stdout of a job reporting its progress,
+// e.g. `::jobd progress=42 msg=docking model 3`, or publishing results, e.g.
+// `::jobd publish=models/*.pdb`
+const progressPrefix = "::jobd "
+
+// progressLineMax is the longest stdout line looked at for a progress report
+const progressLineMax = 4096
+
+// Progress is the last advance reported by a running job
+type Progress struct {
+	Percent int       `json:"percent"`
+	Message string    `json:"message"`
+	Updated time.Time `json:"updated"`
+}
+
+// progressReport is a parsed report, either field can be missing
+type progressReport struct {
+	percent    int
+	message    string
+	hasPercent bool
+	hasMessage bool
+}
+
+// parseProgress reads a report `progress=<percent> msg=<message>`, the message
+// takes the rest of the line; a bare number is a percentage
+func parseProgress(line string) (progressReport, bool) {
+	r := progressReport{}
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), progressPrefix))
+
+	if percent, err := strconv.ParseFloat(line, 64); err == nil {
+		r.percent, r.hasPercent = clampPercent(percent), true
+		return r, true
+	}
+
+	fields, message, found := strings.Cut(line, "msg=")
+	if found {
+		r.message, r.hasMessage = strings.TrimSpace(message), true
+	}
+	for _, field := range strings.Fields(fields) {
+		v, found := strings.CutPrefix(field, "progress=")
+		if !found {
+			continue
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil {
+			continue
+		}
+		r.percent, r.hasPercent = clampPercent(percent), true
+	}
+
+	return r, r.hasPercent || r.hasMessage
+}
+
+// clampPercent keeps a percentage between 0 and 100
+func clampPercent(p float64) int {
+	return int(min(max(p, 0), 100))
+}
+
+// ProgressPath returns the path of the file the job can write its progress to
+func (j *Job) ProgressPath() string {
+	return filepath.Join(j.LogPath, ProgressFile)
+}
+
+// progressTracker follows the progress reported by a running job, in its stdout
+// and in its progress file, and saves it in the record of the job; it also
+// collects the patterns of the results the job publishes
+type progressTracker struct {
+	id   string
+	file string
+
+	mu          sync.Mutex
+	progress    *Progress
+	changed     bool
+	line        []byte
+	fileContent string
+	published   []string
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// trackProgress starts following the progress of the job, the progress file is
+// only available when the job has a log directory
+func (j *Job) trackProgress() *progressTracker {
+	t := &progressTracker{
+		id:      j.ID,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if j.LogPath != "" {
+		// The job runs in its own directory
+		t.file, _ = filepath.Abs(j.ProgressPath())
+		// Left by a previous attempt
+		_ = os.Remove(t.file)
+	}
+
+	go t.poll()
+	return t
+}
+
+// Write scans the stdout of the job for progress and publish lines
+func (t *progressTracker) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.line = append(t.line, p...)
+	for {
+		i := bytes.IndexByte(t.line, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(t.line[:i])
+		t.line = t.line[i+1:]
+
+		directive, found := strings.CutPrefix(line, progressPrefix)
+		if !found {
+			continue
+		}
+		if pattern, found := strings.CutPrefix(directive, "publish="); found {
+			t.published = append(t.published, strings.TrimSpace(pattern))
+			continue
+		}
+		t.report(line)
+	}
+
+	// Too long to be a progress report
+	if len(t.line) > progressLineMax {
+		t.line = t.line[:0]
+	}
+
+	return len(p), nil
+}
+
+// report records a progress report, what it leaves out is kept from the
+// previous one; the caller holds the lock
+func (t *progressTracker) report(line string) {
+	r, ok := parseProgress(line)
+	if !ok {
+		return
+	}
+
+	p := Progress{}
+	if t.progress != nil {
+		p = *t.progress
+	}
+	if r.hasPercent {
+		p.Percent = r.percent
+	}
+	if r.hasMessage {
+		p.Message = r.message
+	}
+	p.Updated = time.Now()
+
+	t.progress = &p
+	t.changed = true
+}
+
+// readFile reports the last line of the progress file if it changed
+func (t *progressTracker) readFile() {
+	if t.file == "" {
+		return
+	}
+	data, err := os.ReadFile(t.file)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if string(data) == t.fileContent {
+		return
+	}
+	t.fileContent = string(data)
+
+	lines := strings.Split(strings.TrimSpace(t.fileContent), "\n")
+	t.report(lines[len(lines)-1])
+}
+
+// publishedPatterns returns the patterns of the results published by the job so far
+func (t *progressTracker) publishedPatterns() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.published)
+}
+
+// save writes the progress in the record of the job if it changed
+func (t *progressTracker) save() {
+	t.mu.Lock()
+	if !t.changed {
+		t.mu.Unlock()
+		return
+	}
+	p := *t.progress
+	t.changed = false
+	t.mu.Unlock()
+
+	j := &Job{ID: t.id}
+	_ = j.Update(func(j *Job) *errors.RestErr {
+		j.Progress = &p
+		return nil
+	})
+}
+
+// poll reads and saves the progress until the tracking stops
+func (t *progressTracker) poll() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(ProgressPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+		t.readFile()
+		t.save()
+	}
+}
+
+// stop ends the tracking and returns the last progress reported by the job
+func (t *progressTracker) stop() *Progress {
+	close(t.done)
+	<-t.stopped
+	t.readFile()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"jobd/utils/testutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   progressReport
+		wantOk bool
+	}{
+		{
+			name:   "percent and message",
+			line:   "::jobd progress=42 msg=docking model 3",
+			want:   progressReport{percent: 42, message: "docking model 3", hasPercent: true, hasMessage: true},
+			wantOk: true,
+		},
+		{
+			name:   "message only",
+			line:   "::jobd msg=refining",
+			want:   progressReport{message: "refining", hasMessage: true},
+			wantOk: true,
+		},
+		{
+			name:   "bare number",
+			line:   "12.5\n",
+			want:   progressReport{percent: 12, hasPercent: true},
+			wantOk: true,
+		},
+		{
+			name:   "out of range",
+			line:   "progress=150%",
+			want:   progressReport{percent: 100, hasPercent: true},
+			wantOk: true,
+		},
+		{
+			name:   "nothing to report",
+			line:   "::jobd hello",
+			want:   progressReport{},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseProgress(tt.line)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseProgress() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestJob_RunProgress(t *testing.T) {
+	defer func(interval time.Duration) { ProgressPollInterval = interval }(ProgressPollInterval)
+	ProgressPollInterval = 10 * time.Millisecond
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-run-progress"
+	logDir := "./test-run-progress-output"
+	_ = os.Mkdir(testDir, 0755)
+	defer os.RemoveAll(testDir)
+	defer os.RemoveAll(logDir)
+
+	// Reports in stdout, waits until jobd saw them, then reports in the file
+	script := "#!/bin/bash\n" +
+		"echo '::jobd progress=30 msg=docking'\n" +
+		"echo 'a regular line'\n" +
+		"while [ ! -f proceed ]; do sleep 0.01; done\n" +
+		"echo 'progress=80' > $JOBD_PROGRESS\n"
+	_ = os.WriteFile(testDir+"/run.sh", []byte(script), 0775)
+
+	j := &Job{ID: "TestJob_RunProgress", Path: testDir, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- j.Run()
+	}()
+
+	// The progress is saved while the job runs
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := &Job{ID: j.ID}
+		_ = got.Get()
+		if got.Progress != nil {
+			if got.Progress.Percent != 30 || got.Progress.Message != "docking" {
+				t.Errorf("Job progress = %+v, want 30%% docking", got.Progress)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job progress was not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = os.WriteFile(testDir+"/proceed", nil, 0644)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Job.Run() did not return")
+	}
+
+	// The message is kept when a report leaves it out
+	got := &Job{ID: j.ID}
+	_ = got.Get()
+	if got.Progress == nil || got.Progress.Percent != 80 || got.Progress.Message != "docking" {
+		t.Errorf("Job progress = %+v, want 80%% docking", got.Progress)
+	}
+
+	stdout, _ := os.ReadFile(j.StdoutPath())
+	if want := "::jobd progress=30 msg=docking\na regular line\n"; string(stdout) != want {
+		t.Errorf("stdout log = %q, want %q", stdout, want)
+	}
+}
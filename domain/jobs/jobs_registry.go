@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"jobd/domain/status"
+	"sync"
+)
+
+// execution is a job being executed by this process
+type execution struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// registry keeps track of the jobs being executed so they can be stopped
+var registry = struct {
+	sync.Mutex
+	jobs map[string]*execution
+}{jobs: map[string]*execution{}}
+
+// track registers a job as being executed; the returned function must be called
+// once the job is no longer executing
+func track(id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	e := &execution{cancel: cancel, done: make(chan struct{})}
+
+	registry.Lock()
+	registry.jobs[id] = e
+	registry.Unlock()
+
+	return ctx, func() {
+		registry.Lock()
+		if registry.jobs[id] == e {
+			delete(registry.jobs, id)
+		}
+		registry.Unlock()
+		cancel(nil)
+		close(e.done)
+	}
+}
+
+// start registers the job as being executed, unless it was stopped after it was
+// claimed; the record is locked meanwhile so it does not interleave with a cancel
+func (j *Job) start() (context.Context, func(), error) {
+	unlock, errLock := j.Lock()
+	if errLock != nil {
+		return nil, nil, errors.New(errLock.Message)
+	}
+	defer unlock()
+
+	current := &Job{ID: j.ID}
+	if current.Get() == nil && status.IsTerminal(current.Status) {
+		return nil, nil, errors.New("job is " + current.Status + ", not executing it")
+	}
+
+	ctx, done := track(j.ID)
+	return ctx, done, nil
+}
+
+// Cancel stops a job being executed by this process with the given cause, the
+// returned channel is closed once the execution is over; returns false if the
+// job is not being executed here
+func Cancel(id string, cause error) (<-chan struct{}, bool) {
+	registry.Lock()
+	e, ok := registry.jobs[id]
+	registry.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	e.cancel(cause)
+	return e.done, true
+}
+
+// CancelAll stops every job being executed by this process with the given cause
+func CancelAll(cause error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, e := range registry.jobs {
+		e.cancel(cause)
+	}
+}
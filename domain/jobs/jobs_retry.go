@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"errors"
+	"jobd/domain/status"
+	"jobd/utils"
+	"strings"
+	"time"
+)
+
+// Failure classes, they tell what went wrong in an attempt
+const (
+	// FailureExit is a job process that failed: non-zero exit, signal, limits or missing outputs
+	FailureExit = "exit"
+	// FailureTimeout is a job that exceeded its time limit
+	FailureTimeout = "timeout"
+	// FailureError is jobd failing to execute the job, e.g. an unreadable input or a slurml error
+	FailureError = "error"
+	// FailurePost is a job that succeeded but whose post-processing failed
+	FailurePost = "post"
+	// FailurePrepare is a job that could not be prepared, e.g. an input that is
+	// not a zip or without run.sh; the same input fails again so it is never retried
+	FailurePrepare = "prepare"
+)
+
+// FailureClasses are the failure classes a retry policy can retry
+var FailureClasses = []string{FailureExit, FailureTimeout, FailureError, FailurePost}
+
+// maxBackoff is the longest a job waits between two attempts
+const maxBackoff = time.Hour
+
+// RetryPolicy says if and when a failed job is executed again
+type RetryPolicy struct {
+	// MaxAttempts is the number of times the job is executed at most, 1 means no retries
+	MaxAttempts int `json:"max_attempts"`
+	// Backoff is how long to wait, in seconds, before the first retry; it doubles
+	// on each one and 0 retries right away
+	Backoff *int `json:"backoff"`
+	// On are the failure classes that are retried: exit, timeout, error and post
+	On []string `json:"on"`
+}
+
+// Attempt is the record of one execution of a job
+type Attempt struct {
+	Number   int                `json:"number"`
+	Status   string             `json:"status"`
+	Failure  string             `json:"failure,omitempty"`
+	Message  string             `json:"message"`
+	Finished time.Time          `json:"finished"`
+	Process  *utils.ProcessInfo `json:"process,omitempty"`
+}
+
+// Validate checks the policy only uses known failure classes
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || (p.Backoff != nil && *p.Backoff < 0) {
+		return errors.New("retry max_attempts and backoff must be positive numbers")
+	}
+	for _, class := range p.On {
+		if !p.known(class) {
+			return errors.New("unknown failure class " + class + ", use one of " + strings.Join(FailureClasses, ", "))
+		}
+	}
+	return nil
+}
+
+func (p RetryPolicy) known(class string) bool {
+	for _, c := range FailureClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// retries reports if the policy retries failures of `class`
+func (p RetryPolicy) retries(class string) bool {
+	for _, c := range p.On {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the attempt following `attempt`
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	d := time.Duration(*p.Backoff) * time.Second
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// failureClass tells why an execution that ended with `s` failed, empty if it did not
+func (j *Job) failureClass() string {
+	switch j.Status {
+	case status.Timeout:
+		return FailureTimeout
+	case status.Failed:
+		if j.failure != "" {
+			return j.failure
+		}
+		if j.postFailed() {
+			return FailurePost
+		}
+		// Without a process jobd could not execute the job
+		if j.Process != nil && !j.Slurml {
+			return FailureExit
+		}
+		return FailureError
+	}
+	return ""
+}
+
+// Ready reports if a job can be executed now, jobs scheduled for later or
+// waiting before a retry are not
+func (j *Job) Ready() bool {
+	return !time.Now().Before(j.NotBefore)
+}
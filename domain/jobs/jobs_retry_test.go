@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"jobd/domain/status"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// seconds returns a backoff of `n` seconds
+func seconds(n int) *int {
+	return &n
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff *int
+		attempt int
+		want    time.Duration
+	}{
+		{name: "first retry", backoff: seconds(10), attempt: 1, want: 10 * time.Second},
+		{name: "doubles", backoff: seconds(10), attempt: 3, want: 40 * time.Second},
+		{name: "capped", backoff: seconds(600), attempt: 10, want: maxBackoff},
+		{name: "no backoff", backoff: seconds(0), attempt: 5, want: 0},
+		{name: "backoff unset", backoff: nil, attempt: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := RetryPolicy{Backoff: tt.backoff}
+			if got := p.delay(tt.attempt); got != tt.want {
+				t.Errorf("RetryPolicy.delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       RetryPolicy
+		wantErr bool
+	}{
+		{name: "empty", p: RetryPolicy{}, wantErr: false},
+		{name: "all classes", p: RetryPolicy{MaxAttempts: 3, On: FailureClasses}, wantErr: false},
+		{name: "unknown class", p: RetryPolicy{On: []string{"oom"}}, wantErr: true},
+		{name: "negative attempts", p: RetryPolicy{MaxAttempts: -1}, wantErr: true},
+		{name: "negative backoff", p: RetryPolicy{Backoff: seconds(-1)}, wantErr: true},
+		{name: "prepare is not retried", p: RetryPolicy{On: []string{FailurePrepare}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("RetryPolicy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJob_Finish(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	retryAll := RetryPolicy{MaxAttempts: 2, Backoff: seconds(60), On: FailureClasses}
+
+	tests := []struct {
+		name         string
+		job          Job
+		wantStatus   string
+		wantFailure  string
+		wantAttempts int
+	}{
+		{
+			name:         "non-zero exit is retried",
+			job:          Job{Status: status.Failed, Process: &utils.ProcessInfo{ExitCode: 1}, Retry: retryAll},
+			wantStatus:   status.Scheduled,
+			wantFailure:  FailureExit,
+			wantAttempts: 1,
+		},
+		{
+			name:         "last attempt",
+			job:          Job{Status: status.Timeout, Retry: retryAll, Attempts: []Attempt{{Number: 1}}},
+			wantStatus:   status.Timeout,
+			wantFailure:  FailureTimeout,
+			wantAttempts: 2,
+		},
+		{
+			name:         "class not retried",
+			job:          Job{Status: status.Failed, Process: &utils.ProcessInfo{ExitCode: 1}, Retry: RetryPolicy{MaxAttempts: 2, On: []string{FailureError}}},
+			wantStatus:   status.Failed,
+			wantFailure:  FailureExit,
+			wantAttempts: 1,
+		},
+		{
+			name:         "executor error",
+			job:          Job{Status: status.Failed, Retry: retryAll},
+			wantStatus:   status.Scheduled,
+			wantFailure:  FailureError,
+			wantAttempts: 1,
+		},
+		{
+			name:         "input that cannot be prepared",
+			job:          Job{Status: status.Failed, Retry: retryAll, failure: FailurePrepare},
+			wantStatus:   status.Failed,
+			wantFailure:  FailurePrepare,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retried right away",
+			job:          Job{Status: status.Failed, Retry: RetryPolicy{MaxAttempts: 2, Backoff: seconds(0), On: FailureClasses}},
+			wantStatus:   status.Queued,
+			wantFailure:  FailureError,
+			wantAttempts: 1,
+		},
+		{
+			name:         "success",
+			job:          Job{Status: status.Success, Retry: retryAll},
+			wantStatus:   status.Success,
+			wantAttempts: 1,
+		},
+		{
+			name:         "cancelled",
+			job:          Job{Status: status.Cancelled, Retry: retryAll},
+			wantStatus:   status.Cancelled,
+			wantAttempts: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := tt.job
+			j.ID = "TestJob_Finish"
+			record := Job{ID: j.ID, Status: status.Running, Attempts: j.Attempts, Retry: j.Retry}
+			testutil.WriteRecord(t, record.ID, record)
+
+			j.Finish(tt.job.Status)
+
+			got := &Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != tt.wantStatus {
+				t.Errorf("Job status = %v, want %v", got.Status, tt.wantStatus)
+			}
+			if len(got.Attempts) != tt.wantAttempts {
+				t.Fatalf("Job attempts = %v, want %v", len(got.Attempts), tt.wantAttempts)
+			}
+			if tt.wantAttempts > 0 && got.Attempts[len(got.Attempts)-1].Failure != tt.wantFailure {
+				t.Errorf("Job failure = %v, want %v", got.Attempts[len(got.Attempts)-1].Failure, tt.wantFailure)
+			}
+			if ready := got.Ready(); ready == (tt.wantStatus == status.Scheduled) {
+				t.Errorf("Job.Ready() = %v right after the attempt", ready)
+			}
+		})
+	}
+}
+
+func TestJob_ExecutePrepareFailure(t *testing.T) {
+
+	testutil.CleanupDB(t)
+
+	testDir := "./test-execute-prepare-failure"
+	defer os.RemoveAll(testDir)
+
+	// Retrying an input without run.sh would fail the same way
+	j := &Job{
+		ID:    "TestJob_ExecutePrepareFailure",
+		Path:  testDir,
+		Input: testutil.ZipBase64(t, map[string]string{"main.sh": "#!/bin/bash\nexit 0"}),
+		Retry: RetryPolicy{MaxAttempts: 3, Backoff: seconds(0), On: FailureClasses},
+	}
+	testutil.WriteRecord(t, j.ID, j)
+	_ = j.Execute()
+
+	got := &Job{ID: j.ID}
+	_ = got.Get()
+	if got.Status != status.Failed {
+		t.Errorf("Job status = %v, want %v (%v)", got.Status, status.Failed, got.Message)
+	}
+	if len(got.Attempts) != 1 || got.Attempts[0].Failure != FailurePrepare {
+		t.Errorf("Job attempts = %+v, want one %v failure", got.Attempts, FailurePrepare)
+	}
+}
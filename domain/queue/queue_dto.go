@@ -0,0 +1,18 @@
+// Package queue provides the domain object describing the state of the queue
+package queue
+
+// Stats describes how busy the local executor is
+type Stats struct {
+	// Workers is the maximum number of local jobs executed at the same time
+	Workers int `json:"workers"`
+	// Running is the number of local jobs being executed
+	Running int `json:"running"`
+	// Queued is the number of jobs waiting to be executed
+	Queued int `json:"queued"`
+	// Scheduled is the number of jobs waiting for their start time
+	Scheduled int `json:"scheduled"`
+	// Waiting is the number of jobs waiting for their parent jobs
+	Waiting int `json:"waiting"`
+	// Held is the number of jobs held until an admin releases them
+	Held int `json:"held"`
+}
@@ -6,14 +6,37 @@ const (
 	Submitted       = "SUBMITTED"
 	Held            = "HELD"
 	Queued          = "QUEUED"
+	Scheduled       = "SCHEDULED"
+	Waiting         = "WAITING"
+	Claimed         = "CLAIMED"
 	Running         = "RUNNING"
 	Deleted         = "DELETED"
 	Post_Processing = "POST_PROCESSING"
 	Cancelled       = "CANCELLED"
 	Failed          = "FAILED"
+	Timeout         = "TIMEOUT"
 	Success         = "SUCCESS"
 	Partial         = "PARTIAL"
 	Unknown         = "UNKNOWN"
 	Prepared        = "PREPARED"
 	Created         = "CREATED"
+	Array           = "ARRAY"
 )
+
+// IsTerminal reports if a job with status `s` will not change anymore
+func IsTerminal(s string) bool {
+	switch s {
+	case Success, Failed, Timeout, Cancelled, Deleted:
+		return true
+	}
+	return false
+}
+
+// IsPending reports if a job with status `s` is waiting to be executed
+func IsPending(s string) bool {
+	switch s {
+	case Queued, Scheduled, Waiting, Held:
+		return true
+	}
+	return false
+}
@@ -56,3 +56,19 @@ func NewStatusAccepted(message string) *RestErr {
 		Error:   "no_content",
 	}
 }
+
+func NewServiceUnavailableError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Status:  http.StatusServiceUnavailable,
+		Error:   "service_unavailable",
+	}
+}
+
+func NewForbiddenError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Status:  http.StatusForbidden,
+		Error:   "forbidden",
+	}
+}
@@ -154,3 +154,63 @@ func TestNewStatusUnauthorized(t *testing.T) {
 		})
 	}
 }
+
+func TestNewServiceUnavailableError(t *testing.T) {
+	type args struct {
+		message string
+	}
+	tests := []struct {
+		name string
+		args args
+		want *RestErr
+	}{
+		{
+			name: "create a new service unavailable error",
+			args: args{
+				message: "test message",
+			},
+			want: &RestErr{
+				Message: "test message",
+				Status:  503,
+				Error:   "service_unavailable",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewServiceUnavailableError(tt.args.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewServiceUnavailableError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewForbiddenError(t *testing.T) {
+	type args struct {
+		message string
+	}
+	tests := []struct {
+		name string
+		args args
+		want *RestErr
+	}{
+		{
+			name: "create a new forbidden error",
+			args: args{
+				message: "test message",
+			},
+			want: &RestErr{
+				Message: "test message",
+				Status:  403,
+				Error:   "forbidden",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewForbiddenError(tt.args.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewForbiddenError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,21 @@
 package main
 
 import (
+	"context"
 	"flag"
 	router "jobd/controllers"
 	"jobd/datasource/db"
+	"jobd/domain/jobs"
 	"jobd/services"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-co-op/gocron"
+	"github.com/golang/glog"
 )
 
 func init() {
@@ -24,11 +31,17 @@ func init() {
 // @description API for managing job queue in jobd application
 // @BasePath /api
 func main() {
+	// Everything that needs the secrets read them, the jobs must not
+	jobs.HideSecrets()
+
 	errDB := db.InitDB()
 	if errDB != nil {
 		log.Fatal(errDB)
 	}
 
+	// Deal with the jobs left behind by a previous run
+	_ = services.RecoverJobs()
+
 	s := gocron.NewScheduler(time.UTC)
 	s.SetMaxConcurrentJobs(1, gocron.RescheduleMode)
 	s.Every(1).Seconds().Do(services.RunTasks)
@@ -36,6 +49,42 @@ func main() {
 	s.Every(1).Hours().Do(services.ClearOldJobs)
 	s.StartAsync()
 
+	// Same address as gin's `r.Run()`
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+
 	r := router.SetupRouter()
-	_ = r.Run()
+	srv := &http.Server{Addr: addr, Handler: r}
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop()
+
+	glog.Info("Shutting down, no longer accepting jobs")
+	services.StopAccepting()
+	s.Stop()
+
+	// Give the running jobs some time to finish, the API stays up meanwhile
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), services.ShutdownTimeout)
+	defer cancelDrain()
+	services.Drain(drainCtx)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		glog.Error("could not shutdown the server cleanly: ", err)
+	}
+
+	glog.Info("Bye")
+	glog.Flush()
 }
@@ -5,7 +5,12 @@ import (
 	"jobd/domain/jobs"
 	"jobd/domain/status"
 	"jobd/errors"
+	"jobd/utils"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 )
@@ -13,11 +18,66 @@ import (
 // const DATAPATH = "/data" // TODO: make this configurable
 var DATAPATH = os.Getenv("DATAPATH")
 
+// JobTimeout is the time limit in seconds given to jobs that do not set one,
+// JobMaxTimeout is the highest limit a job can ask for; 0 means unlimited
+var (
+	JobTimeout    = int(utils.GetEnvInt64("JOB_TIMEOUT", 0))
+	JobMaxTimeout = int(utils.GetEnvInt64("JOB_MAX_TIMEOUT", 0))
+)
+
+// JobMaxLimits are the highest resource limits a job can ask for, jobs that
+// do not ask for a limit get the maximum; 0 means unlimited
+var JobMaxLimits = utils.Limits{
+	MemoryMB:   utils.GetEnvInt64("JOB_MAX_MEMORY_MB", 0),
+	CPUSeconds: utils.GetEnvInt64("JOB_MAX_CPU_SECONDS", 0),
+	FileSizeMB: utils.GetEnvInt64("JOB_MAX_FILE_SIZE_MB", 0),
+	Processes:  utils.GetEnvInt64("JOB_MAX_PROCESSES", 0),
+}
+
+var jobRetryBackoff = int(utils.GetEnvInt64("JOB_RETRY_BACKOFF", 30))
+
+// JobRetry is the retry policy of the jobs that do not bring their own, read from
+// JOB_RETRY_ATTEMPTS, JOB_RETRY_BACKOFF and JOB_RETRY_ON (comma separated classes)
+var JobRetry = jobs.RetryPolicy{
+	MaxAttempts: int(utils.GetEnvInt64("JOB_RETRY_ATTEMPTS", 1)),
+	Backoff:     &jobRetryBackoff,
+	On:          retryClasses(os.Getenv("JOB_RETRY_ON")),
+}
+
+// JobOutputSelection selects the output files of every job, read from
+// JOB_OUTPUT_INCLUDE and JOB_OUTPUT_EXCLUDE (comma separated patterns) and
+// JOB_OUTPUT_EXCLUDE_INPUTS
+var JobOutputSelection = jobs.OutputSelection{
+	Include:       splitList(os.Getenv("JOB_OUTPUT_INCLUDE")),
+	Exclude:       splitList(os.Getenv("JOB_OUTPUT_EXCLUDE")),
+	ExcludeInputs: os.Getenv("JOB_OUTPUT_EXCLUDE_INPUTS") == "true",
+}
+
+// JobMaxAttempts is the highest number of attempts a job can ask for
+var JobMaxAttempts = int(utils.GetEnvInt64("JOB_RETRY_MAX_ATTEMPTS", 10))
+
+// JobMaxPriority is the highest priority an upload can ask for, by default an
+// upload cannot raise its priority above the others; admins can set any
+var JobMaxPriority = int(utils.GetEnvInt64("JOB_MAX_PRIORITY", 0))
+
+// LOGPATH is where the stdout/stderr of the jobs are kept
+var LOGPATH = os.Getenv("LOGPATH")
+
 func init() {
 	if DATAPATH == "" {
 		glog.Warning("DATAPATH not set, using default `./data`")
 		DATAPATH = "./data"
 	}
+	if LOGPATH == "" {
+		glog.Warning("LOGPATH not set, using default `./logs`")
+		LOGPATH = "./logs"
+	}
+	if err := JobRetry.Validate(); err != nil {
+		glog.Warning("invalid default retry policy: ", err)
+	}
+	if err := JobOutputSelection.Validate(); err != nil {
+		glog.Warning("invalid default output selection: ", err)
+	}
 }
 
 // GetJob gets a job from the database
@@ -30,25 +90,126 @@ func GetJob(j jobs.Job) (*jobs.Job, *errors.RestErr) {
 		return nil, err
 	}
 
+	// The output of an array job combines the ones of its children
+	if result.Array != nil {
+		return getArray(result)
+	}
+
+	// The snapshot of the results of a running job is kept out of its record
+	if result.Status == status.Partial && result.Output == "" {
+		result.Output = result.PartialOutput()
+	}
+
 	// If the status is success or failed, return the job
 	// Else return a 202 Accepted
-	validStatus := []string{status.Success, status.Failed, status.Partial}
+	validStatus := []string{status.Success, status.Failed, status.Timeout, status.Cancelled, status.Partial}
 	for _, s := range validStatus {
 		if result.Status == s {
 			return result, nil
 		}
 	}
 
+	switch result.Status {
+	case status.Scheduled:
+		return nil, errors.NewStatusAccepted("job scheduled to start at " + result.NotBefore.Format(time.RFC3339))
+	case status.Waiting:
+		return nil, errors.NewStatusAccepted("job waiting for its parent jobs " + strings.Join(result.DependsOn, ", "))
+	case status.Held:
+		return nil, errors.NewStatusAccepted("job held, waiting to be released")
+	case status.Post_Processing:
+		return nil, errors.NewStatusAccepted("job finished, post-processing its results")
+	case status.Running:
+		if p := result.Progress; p != nil {
+			return nil, errors.NewStatusAccepted("job running, " + strconv.Itoa(p.Percent) + "% done: " + p.Message)
+		}
+	}
+
 	return nil, errors.NewStatusAccepted("job not ready")
 
 }
 
+// getArray returns an array job with the combined output of its children once
+// all of them are finished
+func getArray(result *jobs.Job) (*jobs.Job, *errors.RestErr) {
+
+	result.Aggregate()
+	if !status.IsTerminal(result.Status) {
+		finished := 0
+		for s, n := range result.Array.Counts {
+			if status.IsTerminal(s) {
+				finished += n
+			}
+		}
+		return nil, errors.NewStatusAccepted("array job not finished, " + strconv.Itoa(finished) + " of " +
+			strconv.Itoa(len(result.Array.Children)) + " jobs done")
+	}
+
+	output, err := result.CombinedOutput()
+	if err != nil {
+		return nil, errors.NewInternalServerError("could not combine the outputs of the array: " + err.Error())
+	}
+	result.Output = output
+
+	return result, nil
+}
+
 // PostJob posts a job to the database and save it to the server
 // func CreateJob(b []byte, id string) (*jobs.Job, *errors.RestErr) {
 func CreateJob(j jobs.Job) (*jobs.Job, *errors.RestErr) {
 
+	if IsDraining() {
+		return nil, errors.NewServiceUnavailableError("jobd is shutting down, not accepting new jobs")
+	}
+
+	manifest, errManifest := jobs.ReadManifest(j.Input)
+	if errManifest != nil {
+		return nil, errors.NewBadRequestError(errManifest.Error())
+	}
+	if manifest != nil && j.Timeout == 0 {
+		j.Timeout = manifest.Timeout
+	}
+
 	j.Path = DATAPATH + "/" + j.ID
-	j.Status = status.Queued
+	j.LogPath = LOGPATH + "/" + j.ID
+	j.Manifest = manifest
+	j.Timeout = effectiveTimeout(j.Timeout)
+	j.Limits = effectiveLimits(j.Limits)
+	j.Retry = effectiveRetry(j.Retry)
+	j.OutputSelection = effectiveOutputSelection(j.OutputSelection)
+	j.Priority = effectivePriority(j.Priority)
+	j.Created = time.Now()
+	// The job taking its input from another one waits for it to succeed
+	if j.InputFrom != "" {
+		source := &jobs.Job{ID: j.InputFrom}
+		if source.Get() != nil {
+			return nil, errors.NewBadRequestError("input_from job " + j.InputFrom + " does not exist")
+		}
+		if source.Array != nil {
+			return nil, errors.NewBadRequestError("input_from job " + j.InputFrom + " is an array job, use one of its children")
+		}
+		if status.IsTerminal(source.Status) && source.Status != status.Success {
+			return nil, errors.NewBadRequestError("input_from job " + j.InputFrom + " did not succeed, it is " + source.Status)
+		}
+		if source.Status != status.Success && !slices.Contains(j.DependsOn, j.InputFrom) {
+			j.DependsOn = append(j.DependsOn, j.InputFrom)
+		}
+	}
+
+	// Jobs with parents wait for them, they are checked by the scheduler
+	for _, parent := range j.DependsOn {
+		p := &jobs.Job{ID: parent}
+		if p.Get() != nil {
+			return nil, errors.NewBadRequestError("parent job " + parent + " does not exist")
+		}
+	}
+	j.Status = j.PendingStatus()
+	if j.StartHeld {
+		j.Status = status.Held
+	}
+
+	if j.Array != nil {
+		return createArray(j)
+	}
 
 	err := j.Save()
 	if err != nil {
@@ -60,3 +221,342 @@ func CreateJob(j jobs.Job) (*jobs.Job, *errors.RestErr) {
 	return &j, nil
 
 }
+
+// createArray saves an array job and its children, which are executed like any
+// other job; the array job itself is never executed
+func createArray(j jobs.Job) (*jobs.Job, *errors.RestErr) {
+
+	children := j.Expand()
+
+	// Check all the children first so the array is not saved halfway
+	j.Array.Children = []string{}
+	for _, child := range children {
+		existing := &jobs.Job{ID: child.ID}
+		if existing.Get() == nil {
+			return nil, errors.NewBadRequestError("job " + child.ID + " of the array already exists")
+		}
+		j.Array.Children = append(j.Array.Children, child.ID)
+	}
+
+	j.Array.Counts = nil
+	j.Status = status.Array
+	// Where the combined output of the children is archived
+	j.LogPath = LOGPATH + "/" + j.ID
+	err := j.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	// A child that cannot be saved takes the whole array back
+	for n, child := range children {
+		child.Path = DATAPATH + "/" + child.ID
+		child.LogPath = LOGPATH + "/" + child.ID
+		err = child.Save()
+		if err != nil {
+			for _, saved := range children[:n] {
+				_ = saved.Delete()
+			}
+			_ = j.Delete()
+			return nil, err
+		}
+	}
+
+	return &j, nil
+}
+
+// CancelJob stops a job whatever its state; queued jobs are simply not executed,
+// running ones are terminated and slurml ones are cancelled remotely
+func CancelJob(j jobs.Job) (*jobs.Job, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+
+	// Cancelling an array job cancels its children
+	if result.Array != nil {
+		for _, id := range result.Array.Children {
+			_, _ = CancelJob(jobs.Job{ID: id})
+		}
+		result.Aggregate()
+		return result, nil
+	}
+
+	// Lock the record so the job cannot be claimed or started meanwhile
+	unlock, err := result.Lock()
+	if err != nil {
+		return nil, err
+	}
+	_ = result.Get()
+
+	if status.IsTerminal(result.Status) {
+		unlock()
+		return nil, errors.NewConflictError("job already finished with status " + result.Status)
+	}
+
+	// Being executed by this process, the executor takes care of the status
+	if done, ok := jobs.Cancel(result.ID, jobs.ErrCancelled); ok {
+		unlock()
+		select {
+		case <-done:
+		case <-time.After(jobs.KillGrace + 5*time.Second):
+			glog.Warning("job ", result.ID, " is taking long to stop")
+		}
+		_ = result.Get()
+		return result, nil
+	}
+	defer unlock()
+
+	if result.Slurml && result.SlurmID != 0 {
+		errSlurml := result.CancelOnSlurml()
+		if errSlurml != nil {
+			glog.Error("could not cancel job ", result.ID, " on slurml: ", errSlurml)
+			return nil, errors.NewInternalServerError("could not cancel the job on slurml: " + errSlurml.Error())
+		}
+	}
+
+	result.AddMessage("job was cancelled")
+	result.UpdateStatus(status.Cancelled)
+
+	return result, nil
+}
+
+// effectiveTimeout applies the server default and maximum to the timeout requested by a job
+func effectiveTimeout(t int) int {
+	if t == 0 {
+		t = JobTimeout
+	}
+	if JobMaxTimeout > 0 && (t == 0 || t > JobMaxTimeout) {
+		t = JobMaxTimeout
+	}
+	return t
+}
+
+// effectiveLimits caps the limits requested by a job with the server maximum
+func effectiveLimits(l utils.Limits) utils.Limits {
+	capped := func(requested, max int64) int64 {
+		if max > 0 && (requested == 0 || requested > max) {
+			return max
+		}
+		return requested
+	}
+
+	return utils.Limits{
+		MemoryMB:   capped(l.MemoryMB, JobMaxLimits.MemoryMB),
+		CPUSeconds: capped(l.CPUSeconds, JobMaxLimits.CPUSeconds),
+		FileSizeMB: capped(l.FileSizeMB, JobMaxLimits.FileSizeMB),
+		Processes:  capped(l.Processes, JobMaxLimits.Processes),
+	}
+}
+
+// splitList reads a comma separated list, without the empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// retryClasses reads the failure classes retried by default, only executor errors if none is set
+func retryClasses(s string) []string {
+	classes := splitList(s)
+	if len(classes) == 0 {
+		return []string{jobs.FailureError}
+	}
+	return classes
+}
+
+// effectiveRetry fills what the job did not set of its retry policy with the
+// server default, the attempts are capped by the server maximum
+func effectiveRetry(p jobs.RetryPolicy) jobs.RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = JobRetry.MaxAttempts
+	}
+	if JobMaxAttempts > 0 && p.MaxAttempts > JobMaxAttempts {
+		p.MaxAttempts = JobMaxAttempts
+	}
+	// A backoff of 0 retries right away, only a missing one takes the default
+	if p.Backoff == nil {
+		p.Backoff = JobRetry.Backoff
+	}
+	if len(p.On) == 0 {
+		p.On = JobRetry.On
+	}
+	return p
+}
+
+// effectiveOutputSelection applies the server selection to the one of a job: the
+// job patterns to include replace the server ones, the patterns to exclude add up
+func effectiveOutputSelection(s jobs.OutputSelection) jobs.OutputSelection {
+	if len(s.Include) == 0 {
+		s.Include = JobOutputSelection.Include
+	}
+	s.Exclude = slices.Concat(JobOutputSelection.Exclude, s.Exclude)
+	s.ExcludeInputs = s.ExcludeInputs || JobOutputSelection.ExcludeInputs
+	return s
+}
+
+// effectivePriority caps the priority an upload asks for
+func effectivePriority(p int) int {
+	if p > JobMaxPriority {
+		return JobMaxPriority
+	}
+	return p
+}
+
+// SetJobPriority changes the priority of a job that is still waiting to be executed
+func SetJobPriority(j jobs.Job, priority int) (*jobs.Job, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+
+	err = result.Update(func(j *jobs.Job) *errors.RestErr {
+		if !status.IsPending(j.Status) {
+			return errors.NewConflictError("only waiting jobs can be reprioritized, job is " + j.Status)
+		}
+		j.Priority = priority
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// HoldJob keeps a job waiting to be executed from being dispatched
+func HoldJob(j jobs.Job) (*jobs.Job, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+
+	err = result.Hold()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ReleaseJob lets a held job be dispatched again
+func ReleaseJob(j jobs.Job) (*jobs.Job, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+
+	err = result.Release()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetJobStatus returns a job whatever its state, without its output
+func GetJobStatus(j jobs.Job) (*jobs.Job, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+
+	if result.Array != nil {
+		result.Aggregate()
+	}
+	result.Output = ""
+	return result, nil
+}
+
+// GetJobGraph returns the dependency graph of a job
+func GetJobGraph(j jobs.Job) (*jobs.Graph, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+
+	graph := result.BuildGraph()
+	return &graph, nil
+}
+
+// GetJobOutput opens the archive of the output of a job: the one kept once it
+// finished, or the last snapshot of its published results while it runs. Jobs
+// without one, like array jobs, get it made from their record on the first request
+func GetJobOutput(j jobs.Job) (*jobs.Archive, *errors.RestErr) {
+
+	if !jobs.ValidID(j.ID) {
+		return nil, errors.NewBadRequestError("invalid job id")
+	}
+
+	record := &jobs.Job{ID: j.ID}
+	if record.Get() != nil {
+		return nil, errors.NewNotFoundError("job not found")
+	}
+	logPath := record.LogPath
+	if logPath == "" {
+		logPath = LOGPATH + "/" + j.ID
+	}
+
+	for _, name := range []string{jobs.OutputArchive, jobs.PartialArchive} {
+		if archive, err := jobs.OpenArchive(logPath, name); err == nil {
+			return archive, nil
+		}
+	}
+
+	result, err := GetJob(j)
+	if err != nil {
+		return nil, err
+	}
+	if result.Output == "" {
+		return nil, errors.NewNotFoundError("job has no output")
+	}
+
+	result.LogPath = logPath
+	archive, errArchive := result.ArchiveOutput()
+	if errArchive != nil {
+		return nil, errors.NewInternalServerError("could not archive the output of the job: " + errArchive.Error())
+	}
+
+	return archive, nil
+}
+
+// GetJobLog returns the path to the stdout or stderr log of a job
+func GetJobLog(j jobs.Job, stream string) (string, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return "", errors.NewNotFoundError("job not found")
+	}
+
+	var path string
+	switch stream {
+	case "stdout":
+		path = result.StdoutPath()
+	case "stderr":
+		path = result.StderrPath()
+	default:
+		return "", errors.NewBadRequestError("unknown log stream " + stream)
+	}
+
+	if _, errStat := os.Stat(path); result.LogPath == "" || errStat != nil {
+		return "", errors.NewNotFoundError("log not available")
+	}
+
+	return path, nil
+}
@@ -1,21 +1,38 @@
 package services
 
 import (
+	"encoding/base64"
+	"io"
 	"jobd/datasource/db"
 	"jobd/domain/jobs"
 	"jobd/domain/status"
 	"jobd/errors"
+	"jobd/utils"
+	"jobd/utils/testutil"
+	"net/http"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-func init() {
+// TestMain keeps the database, the job directories and the logs of the tests in
+// a temporary directory removed once they are done
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "jobd-services-test-")
+	if err != nil {
+		panic(err)
+	}
+	DATAPATH, LOGPATH, db.NAME = dir+"/data", dir+"/logs", dir+"/db"
 	_ = db.InitDB()
 	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
 }
 
 func TestGetJob(t *testing.T) {
@@ -29,6 +46,10 @@ func TestGetJob(t *testing.T) {
 	runningJ := &jobs.Job{ID: "TestGetJobRunning", Status: status.Running}
 	_ = db.Client.Write(db.NAME, runningJ.ID, runningJ)
 
+	// Running and reporting its progress
+	progressJ := &jobs.Job{ID: "TestGetJobProgress", Status: status.Running, Progress: &jobs.Progress{Percent: 42, Message: "docking"}}
+	_ = db.Client.Write(db.NAME, progressJ.ID, progressJ)
+
 	// Partial
 	partialJ := &jobs.Job{ID: "TestGetJobPartial", Status: status.Partial}
 	_ = db.Client.Write(db.NAME, partialJ.ID, partialJ)
@@ -75,6 +96,16 @@ func TestGetJob(t *testing.T) {
 			want:  nil,
 			want1: errors.NewStatusAccepted("job not ready"),
 		},
+		{
+			name: "GetJobProgress",
+			args: args{
+				j: jobs.Job{
+					ID: "TestGetJobProgress",
+				},
+			},
+			want:  nil,
+			want1: errors.NewStatusAccepted("job running, 42% done: docking"),
+		},
 		{
 			name: "GetJobPartial",
 			args: args{
@@ -139,9 +170,17 @@ func TestCreateJob(t *testing.T) {
 	// Add a job to the database
 	j := &jobs.Job{ID: "existing-job-test-create-job"}
 	_ = db.Client.Write(db.NAME, j.ID, j)
+	done := &jobs.Job{ID: "done-job-test-create-job", Status: status.Success}
+	_ = db.Client.Write(db.NAME, done.ID, done)
+	failed := &jobs.Job{ID: "failed-job-test-create-job", Status: status.Failed}
+	_ = db.Client.Write(db.NAME, failed.ID, failed)
 
 	defer os.RemoveAll(db.NAME)
 
+	later := time.Now().Add(time.Hour).Round(0)
+	manifestInput := testutil.ZipBase64(t, map[string]string{"jobd.yaml": "command: main.sh\ntimeout: 60\n"})
+	invalidInput := testutil.ZipBase64(t, map[string]string{"jobd.json": "{"})
+
 	type args struct {
 		j jobs.Job
 	}
@@ -160,9 +199,11 @@ func TestCreateJob(t *testing.T) {
 				},
 			},
 			want: &jobs.Job{
-				ID:     "TestCreateJob",
-				Status: "QUEUED",
-				Path:   DATAPATH + "/TestCreateJob",
+				ID:      "TestCreateJob",
+				Status:  "QUEUED",
+				Path:    DATAPATH + "/TestCreateJob",
+				LogPath: LOGPATH + "/TestCreateJob",
+				Retry:   JobRetry,
 			},
 			want1: nil,
 		},
@@ -176,6 +217,161 @@ func TestCreateJob(t *testing.T) {
 			want:  nil,
 			want1: errors.NewBadRequestError("job already exists"),
 		},
+		{
+			name: "CreateJobWithManifest",
+			args: args{
+				j: jobs.Job{
+					ID:    "TestCreateJobWithManifest",
+					Input: manifestInput,
+				},
+			},
+			want: &jobs.Job{
+				ID:       "TestCreateJobWithManifest",
+				Status:   "QUEUED",
+				Path:     DATAPATH + "/TestCreateJobWithManifest",
+				LogPath:  LOGPATH + "/TestCreateJobWithManifest",
+				Input:    manifestInput,
+				Timeout:  60,
+				Manifest: &jobs.Manifest{Command: "main.sh", Timeout: 60},
+				Retry:    JobRetry,
+			},
+			want1: nil,
+		},
+		{
+			name: "CreateJobScheduled",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobScheduled",
+					NotBefore: later,
+				},
+			},
+			want: &jobs.Job{
+				ID:        "TestCreateJobScheduled",
+				Status:    "SCHEDULED",
+				Path:      DATAPATH + "/TestCreateJobScheduled",
+				LogPath:   LOGPATH + "/TestCreateJobScheduled",
+				Retry:     JobRetry,
+				NotBefore: later,
+			},
+			want1: nil,
+		},
+		{
+			name: "CreateJobWithParent",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobWithParent",
+					DependsOn: []string{"existing-job-test-create-job"},
+				},
+			},
+			want: &jobs.Job{
+				ID:        "TestCreateJobWithParent",
+				Status:    "WAITING",
+				Path:      DATAPATH + "/TestCreateJobWithParent",
+				LogPath:   LOGPATH + "/TestCreateJobWithParent",
+				Retry:     JobRetry,
+				DependsOn: []string{"existing-job-test-create-job"},
+			},
+			want1: nil,
+		},
+		{
+			name: "FailCreateJobUnknownParent",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobUnknownParent",
+					DependsOn: []string{"TestCreateJob-missing"},
+				},
+			},
+			want:  nil,
+			want1: errors.NewBadRequestError("parent job TestCreateJob-missing does not exist"),
+		},
+		{
+			name: "CreateJobInputFromFinished",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobInputFromFinished",
+					InputFrom: "done-job-test-create-job",
+				},
+			},
+			want: &jobs.Job{
+				ID:        "TestCreateJobInputFromFinished",
+				Status:    "QUEUED",
+				Path:      DATAPATH + "/TestCreateJobInputFromFinished",
+				LogPath:   LOGPATH + "/TestCreateJobInputFromFinished",
+				Retry:     JobRetry,
+				InputFrom: "done-job-test-create-job",
+			},
+			want1: nil,
+		},
+		{
+			name: "CreateJobInputFromUnfinished",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobInputFromUnfinished",
+					InputFrom: "existing-job-test-create-job",
+				},
+			},
+			want: &jobs.Job{
+				ID:        "TestCreateJobInputFromUnfinished",
+				Status:    "WAITING",
+				Path:      DATAPATH + "/TestCreateJobInputFromUnfinished",
+				LogPath:   LOGPATH + "/TestCreateJobInputFromUnfinished",
+				Retry:     JobRetry,
+				DependsOn: []string{"existing-job-test-create-job"},
+				InputFrom: "existing-job-test-create-job",
+			},
+			want1: nil,
+		},
+		{
+			name: "FailCreateJobInputFromFailed",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobInputFromFailed",
+					InputFrom: "failed-job-test-create-job",
+				},
+			},
+			want:  nil,
+			want1: errors.NewBadRequestError("input_from job failed-job-test-create-job did not succeed, it is FAILED"),
+		},
+		{
+			name: "FailCreateJobInputFromUnknown",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobInputFromUnknown",
+					InputFrom: "TestCreateJob-missing",
+				},
+			},
+			want:  nil,
+			want1: errors.NewBadRequestError("input_from job TestCreateJob-missing does not exist"),
+		},
+		{
+			name: "CreateJobHeld",
+			args: args{
+				j: jobs.Job{
+					ID:        "TestCreateJobHeld",
+					StartHeld: true,
+				},
+			},
+			want: &jobs.Job{
+				ID:        "TestCreateJobHeld",
+				Status:    "HELD",
+				Path:      DATAPATH + "/TestCreateJobHeld",
+				LogPath:   LOGPATH + "/TestCreateJobHeld",
+				Retry:     JobRetry,
+				StartHeld: true,
+			},
+			want1: nil,
+		},
+		{
+			name: "FailCreateJobInvalidManifest",
+			args: args{
+				j: jobs.Job{
+					ID:    "TestCreateJobInvalidManifest",
+					Input: invalidInput,
+				},
+			},
+			want:  nil,
+			want1: errors.NewBadRequestError("invalid jobd.json: unexpected EOF"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -184,6 +380,7 @@ func TestCreateJob(t *testing.T) {
 				// Warning: This is a hack to get the test to pass
 				//   Overwrite the time with the expected time
 				got.LastUpdated = tt.want.LastUpdated
+				got.Created = tt.want.Created
 			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("CreateJob() got = %v, want %v", got, tt.want)
@@ -194,3 +391,373 @@ func TestCreateJob(t *testing.T) {
 		})
 	}
 }
+
+func TestGetJobLog(t *testing.T) {
+	logDir := "./test-get-job-log"
+	_ = os.MkdirAll(logDir, 0755)
+	_ = os.WriteFile(logDir+"/"+jobs.StdoutLog, []byte("hello"), 0644)
+	defer os.RemoveAll(logDir)
+
+	j := &jobs.Job{ID: "TestGetJobLog", Status: status.Running, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+
+	testutil.CleanupDB(t)
+
+	tests := []struct {
+		name   string
+		id     string
+		stream string
+		want   string
+		want1  *errors.RestErr
+	}{
+		{
+			name:   "GetJobLogStdout",
+			id:     "TestGetJobLog",
+			stream: "stdout",
+			want:   j.StdoutPath(),
+		},
+		{
+			name:   "GetJobLogMissingStderr",
+			id:     "TestGetJobLog",
+			stream: "stderr",
+			want1:  errors.NewNotFoundError("log not available"),
+		},
+		{
+			name:   "GetJobLogNonExisting",
+			id:     uuid.New().String(),
+			stream: "stdout",
+			want1:  errors.NewNotFoundError("job not found"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, got1 := GetJobLog(jobs.Job{ID: tt.id}, tt.stream)
+			if got != tt.want {
+				t.Errorf("GetJobLog() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(got1, tt.want1) {
+				t.Errorf("GetJobLog() got1 = %v, want %v", got1, tt.want1)
+			}
+		})
+	}
+}
+
+func TestGetJobOutput(t *testing.T) {
+	testutil.CleanupDB(t)
+
+	for _, j := range []*jobs.Job{
+		{ID: "TestGetJobOutput", Status: status.Success, Output: base64.StdEncoding.EncodeToString([]byte("zip"))},
+		{ID: "TestGetJobOutputEmpty", Status: status.Failed},
+		{ID: "TestGetJobOutputInvalid", Status: status.Success, Output: "not base64!"},
+		{ID: "TestGetJobOutputRunning", Status: status.Running},
+	} {
+		testutil.WriteRecord(t, j.ID, j)
+		defer os.RemoveAll(LOGPATH + "/" + j.ID)
+	}
+
+	// The archive kept by a job that finished is served from its log directory
+	archived := "./test-get-job-output-archived"
+	testutil.WriteRecord(t, "TestGetJobOutputArchived", &jobs.Job{ID: "TestGetJobOutputArchived", Status: status.Success, LogPath: archived})
+	for _, dir := range []string{archived, LOGPATH + "/TestGetJobOutputUnknown"} {
+		_ = os.MkdirAll(dir, 0755)
+		defer os.RemoveAll(dir)
+		_ = os.WriteFile(dir+"/"+jobs.OutputArchive+".zip", []byte("archived zip"), 0644)
+		_ = os.WriteFile(dir+"/"+jobs.OutputArchive+".json", []byte(`{"id":"TestGetJobOutputArchived","status":"SUCCESS","sha256":"sum"}`), 0644)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		want       []byte
+		wantSHA256 string
+		wantStatus int
+	}{
+		{name: "GetJobOutput", id: "TestGetJobOutput", want: []byte("zip"),
+			// sha256sum of "zip"
+			wantSHA256: "4a70fe9aa6436e02c2dea340fbd1e352e4ef2d8ce6ca52ad25d4b95471fc8bf2"},
+		{name: "GetJobOutputArchived", id: "TestGetJobOutputArchived", want: []byte("archived zip"), wantSHA256: "sum"},
+		{name: "GetJobOutputEmpty", id: "TestGetJobOutputEmpty", wantStatus: http.StatusNotFound},
+		{name: "GetJobOutputInvalid", id: "TestGetJobOutputInvalid", wantStatus: http.StatusInternalServerError},
+		{name: "GetJobOutputRunning", id: "TestGetJobOutputRunning", wantStatus: http.StatusAccepted},
+		{name: "GetJobOutputNonExisting", id: uuid.New().String(), wantStatus: http.StatusNotFound},
+		// Archives are only served for the jobs in the database
+		{name: "GetJobOutputUnknown", id: "TestGetJobOutputUnknown", wantStatus: http.StatusNotFound},
+		{name: "GetJobOutputOutsideLogs", id: "..", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive, err := GetJobOutput(jobs.Job{ID: tt.id})
+			if (err == nil && tt.wantStatus != 0) || (err != nil && err.Status != tt.wantStatus) {
+				t.Fatalf("GetJobOutput() error = %v, want status %v", err, tt.wantStatus)
+			}
+			if err != nil {
+				return
+			}
+			defer archive.Close()
+
+			got, _ := io.ReadAll(archive)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetJobOutput() got = %v, want %v", got, tt.want)
+			}
+			if archive.SHA256 != tt.wantSHA256 {
+				t.Errorf("GetJobOutput() SHA-256 = %v, want %v", archive.SHA256, tt.wantSHA256)
+			}
+		})
+	}
+}
+
+func TestEffectiveTimeout(t *testing.T) {
+	defer func(d, m int) { JobTimeout, JobMaxTimeout = d, m }(JobTimeout, JobMaxTimeout)
+
+	tests := []struct {
+		name       string
+		timeout    int
+		defaultT   int
+		maxTimeout int
+		want       int
+	}{
+		{name: "unlimited", timeout: 0, defaultT: 0, maxTimeout: 0, want: 0},
+		{name: "requested", timeout: 30, defaultT: 0, maxTimeout: 0, want: 30},
+		{name: "default", timeout: 0, defaultT: 60, maxTimeout: 0, want: 60},
+		{name: "capped", timeout: 600, defaultT: 60, maxTimeout: 300, want: 300},
+		{name: "unlimited-capped", timeout: 0, defaultT: 0, maxTimeout: 300, want: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			JobTimeout, JobMaxTimeout = tt.defaultT, tt.maxTimeout
+			if got := effectiveTimeout(tt.timeout); got != tt.want {
+				t.Errorf("effectiveTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	queuedJ := &jobs.Job{ID: "TestCancelJobQueued", Status: status.Queued}
+	testutil.WriteRecord(t, queuedJ.ID, queuedJ)
+
+	finishedJ := &jobs.Job{ID: "TestCancelJobFinished", Status: status.Success}
+	testutil.WriteRecord(t, finishedJ.ID, finishedJ)
+
+	testutil.CleanupDB(t)
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus string
+		want1      *errors.RestErr
+	}{
+		{
+			name:       "CancelQueuedJob",
+			id:         queuedJ.ID,
+			wantStatus: status.Cancelled,
+		},
+		{
+			name:  "CancelFinishedJob",
+			id:    finishedJ.ID,
+			want1: errors.NewConflictError("job already finished with status SUCCESS"),
+		},
+		{
+			name:  "CancelNonExistingJob",
+			id:    uuid.New().String(),
+			want1: errors.NewNotFoundError("job not found"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, got1 := CancelJob(jobs.Job{ID: tt.id})
+			if got != nil && got.Status != tt.wantStatus {
+				t.Errorf("CancelJob() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(got1, tt.want1) {
+				t.Errorf("CancelJob() got1 = %v, want %v", got1, tt.want1)
+			}
+		})
+	}
+}
+
+func TestEffectiveLimits(t *testing.T) {
+	defer func(l utils.Limits) { JobMaxLimits = l }(JobMaxLimits)
+	JobMaxLimits = utils.Limits{MemoryMB: 1024, CPUSeconds: 0, FileSizeMB: 100, Processes: 64}
+
+	got := effectiveLimits(utils.Limits{MemoryMB: 4096, CPUSeconds: 60, FileSizeMB: 0, Processes: 8})
+	want := utils.Limits{MemoryMB: 1024, CPUSeconds: 60, FileSizeMB: 100, Processes: 8}
+	if got != want {
+		t.Errorf("effectiveLimits() = %v, want %v", got, want)
+	}
+}
+
+func TestEffectiveRetry(t *testing.T) {
+	defer func(p jobs.RetryPolicy, max int) { JobRetry, JobMaxAttempts = p, max }(JobRetry, JobMaxAttempts)
+	backoff := func(n int) *int { return &n }
+	JobRetry = jobs.RetryPolicy{MaxAttempts: 2, Backoff: backoff(30), On: []string{jobs.FailureError}}
+	JobMaxAttempts = 5
+
+	tests := []struct {
+		name string
+		p    jobs.RetryPolicy
+		want jobs.RetryPolicy
+	}{
+		{
+			name: "server default",
+			p:    jobs.RetryPolicy{},
+			want: jobs.RetryPolicy{MaxAttempts: 2, Backoff: backoff(30), On: []string{jobs.FailureError}},
+		},
+		{
+			name: "job policy capped",
+			p:    jobs.RetryPolicy{MaxAttempts: 10, Backoff: backoff(5), On: []string{jobs.FailureTimeout}},
+			want: jobs.RetryPolicy{MaxAttempts: 5, Backoff: backoff(5), On: []string{jobs.FailureTimeout}},
+		},
+		{
+			name: "retried right away",
+			p:    jobs.RetryPolicy{MaxAttempts: 3, Backoff: backoff(0)},
+			want: jobs.RetryPolicy{MaxAttempts: 3, Backoff: backoff(0), On: []string{jobs.FailureError}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveRetry(tt.p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("effectiveRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveOutputSelection(t *testing.T) {
+	defer func(s jobs.OutputSelection) { JobOutputSelection = s }(JobOutputSelection)
+	JobOutputSelection = jobs.OutputSelection{Include: []string{"*.pdb"}, Exclude: []string{"scratch"}}
+
+	tests := []struct {
+		name string
+		s    jobs.OutputSelection
+		want jobs.OutputSelection
+	}{
+		{
+			name: "server default",
+			s:    jobs.OutputSelection{},
+			want: jobs.OutputSelection{Include: []string{"*.pdb"}, Exclude: []string{"scratch"}},
+		},
+		{
+			name: "job includes replace the server ones",
+			s:    jobs.OutputSelection{Include: []string{"results", "*.csv"}},
+			want: jobs.OutputSelection{Include: []string{"results", "*.csv"}, Exclude: []string{"scratch"}},
+		},
+		{
+			name: "job excludes only",
+			s:    jobs.OutputSelection{Exclude: []string{"*.tmp"}},
+			want: jobs.OutputSelection{Include: []string{"*.pdb"}, Exclude: []string{"scratch", "*.tmp"}},
+		},
+		{
+			name: "job selection",
+			s:    jobs.OutputSelection{Include: []string{"results"}, Exclude: []string{"*.tmp"}, ExcludeInputs: true},
+			want: jobs.OutputSelection{Include: []string{"results"}, Exclude: []string{"scratch", "*.tmp"}, ExcludeInputs: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveOutputSelection(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("effectiveOutputSelection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateJobOutputSelection(t *testing.T) {
+	testutil.CleanupDB(t)
+	defer func(s jobs.OutputSelection) { JobOutputSelection = s }(JobOutputSelection)
+	JobOutputSelection = jobs.OutputSelection{Include: []string{"*.pdb"}, Exclude: []string{"scratch"}, ExcludeInputs: true}
+
+	// The job includes replace the server ones in the saved job
+	input := testutil.ZipBase64(t, map[string]string{"run.sh": "#!/bin/bash\necho ok"})
+	_, err := CreateJob(jobs.Job{ID: "TestCreateJobOutputSelection", Input: input, OutputSelection: jobs.OutputSelection{Include: []string{"results"}}})
+	if err != nil {
+		t.Fatalf("CreateJob() error = %v", err)
+	}
+
+	got := &jobs.Job{ID: "TestCreateJobOutputSelection"}
+	if err := got.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	want := jobs.OutputSelection{Include: []string{"results"}, Exclude: []string{"scratch"}, ExcludeInputs: true}
+	if !reflect.DeepEqual(got.OutputSelection, want) {
+		t.Errorf("CreateJob() output selection = %+v, want %+v", got.OutputSelection, want)
+	}
+}
+
+func TestEffectivePriority(t *testing.T) {
+	defer func(max int) { JobMaxPriority = max }(JobMaxPriority)
+
+	// Only admins raise priorities by default
+	JobMaxPriority = 0
+	if got := effectivePriority(1000); got != 0 {
+		t.Errorf("effectivePriority() = %v, want %v", got, 0)
+	}
+
+	JobMaxPriority = 10
+	if got := effectivePriority(1000); got != 10 {
+		t.Errorf("effectivePriority() = %v, want %v", got, 10)
+	}
+	if got := effectivePriority(-5); got != -5 {
+		t.Errorf("effectivePriority() = %v, want %v", got, -5)
+	}
+}
+
+func TestCreateArray(t *testing.T) {
+	testutil.CleanupDB(t)
+
+	taken := &jobs.Job{ID: "TestCreateArrayTaken-2"}
+	testutil.WriteRecord(t, taken.ID, taken)
+
+	// Some of the children already exist, nothing is saved
+	_, err := CreateJob(jobs.Job{ID: "TestCreateArrayTaken", Array: &jobs.Array{From: 1, To: 3}})
+	if err == nil || err.Status != 400 {
+		t.Fatalf("CreateJob() error = %v, want a bad request", err)
+	}
+	if (&jobs.Job{ID: "TestCreateArrayTaken-1"}).Get() == nil {
+		t.Errorf("CreateJob() saved a child of a rejected array")
+	}
+
+	input := testutil.ZipBase64(t, map[string]string{"run.sh": "#!/bin/bash\necho ok"})
+	got, err := CreateJob(jobs.Job{ID: "TestCreateArray", Input: input, Array: &jobs.Array{From: 1, To: 2}})
+	if err != nil {
+		t.Fatalf("CreateJob() error = %v", err)
+	}
+	// The input is only kept by the array job
+	if child := (&jobs.Job{ID: "TestCreateArray-1"}); child.Get() != nil || child.Input != "" {
+		t.Errorf("CreateJob() saved the input in the children")
+	}
+	if got.Status != status.Array {
+		t.Errorf("CreateJob() status = %v, want %v", got.Status, status.Array)
+	}
+	if want := []string{"TestCreateArray-1", "TestCreateArray-2"}; !reflect.DeepEqual(got.Array.Children, want) {
+		t.Errorf("CreateJob() children = %v, want %v", got.Array.Children, want)
+	}
+	if queued, _ := jobs.ListQueued(); len(queued) != 2 {
+		t.Errorf("ListQueued() = %d jobs, want the 2 children", len(queued))
+	}
+
+	// The array is done once all the children are
+	_, err = GetJob(jobs.Job{ID: "TestCreateArray"})
+	if !reflect.DeepEqual(err, errors.NewStatusAccepted("array job not finished, 0 of 2 jobs done")) {
+		t.Errorf("GetJob() error = %v, want array job not finished", err)
+	}
+
+	for _, id := range got.Array.Children {
+		child := &jobs.Job{ID: id}
+		_ = child.Get()
+		child.Status = status.Success
+		child.Output = testutil.ZipBase64(t, map[string]string{"out.txt": id})
+		testutil.WriteRecord(t, child.ID, child)
+	}
+
+	result, err := GetJob(jobs.Job{ID: "TestCreateArray"})
+	if err != nil {
+		t.Fatalf("GetJob() error = %v", err)
+	}
+	if result.Status != status.Success || result.Array.Counts[status.Success] != 2 {
+		t.Errorf("GetJob() status = %v counts = %v, want 2 %v", result.Status, result.Array.Counts, status.Success)
+	}
+	if result.Output == "" {
+		t.Errorf("GetJob() output is empty, want the combined outputs")
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"jobd/domain/jobs"
+	"jobd/domain/status"
+	"jobd/errors"
+	"os"
+	"time"
+)
+
+// LogPollInterval is how often the logs of a job are checked for new output while streaming
+var LogPollInterval = 500 * time.Millisecond
+
+// logChunkSize is the maximum amount of log sent at once
+const logChunkSize = 64 * 1024
+
+// LogOffsets are the bytes of each log of a job already sent to a client
+type LogOffsets struct {
+	Stdout int64
+	Stderr int64
+}
+
+// LogChunk is new output of a job, Offsets are the ones right after it
+type LogChunk struct {
+	Stream  string
+	Data    []byte
+	Offsets LogOffsets
+}
+
+// StreamJobLogs sends the logs of a job from `offsets` as they are written, and
+// its progress as JSON in a "progress" chunk whenever it changes, until the job
+// reaches a terminal state (all children of an array job are finished), `ctx`
+// is done or `send` returns false.
+// Returns the last known status of the job
+func StreamJobLogs(ctx context.Context, j jobs.Job, offsets LogOffsets, send func(LogChunk) bool) (string, *errors.RestErr) {
+
+	result := &jobs.Job{ID: j.ID}
+	err := result.Get()
+	if err != nil {
+		return "", errors.NewNotFoundError("job not found")
+	}
+
+	ticker := time.NewTicker(LogPollInterval)
+	defer ticker.Stop()
+
+	var progressSent time.Time
+	for {
+		// The status of an array job is the one of its children
+		if result.Array != nil {
+			result.Aggregate()
+		}
+
+		// The status is read before the logs, so nothing written before the job
+		// finished is missed
+		finished := status.IsTerminal(result.Status)
+
+		for _, stream := range []string{"stdout", "stderr"} {
+			if !sendLog(result, stream, &offsets, finished, send) {
+				return result.Status, nil
+			}
+		}
+
+		if p := result.Progress; p != nil && p.Updated.After(progressSent) {
+			data, _ := json.Marshal(p)
+			if !send(LogChunk{Stream: "progress", Data: data, Offsets: offsets}) {
+				return result.Status, nil
+			}
+			progressSent = p.Updated
+		}
+
+		if finished {
+			return result.Status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result.Status, nil
+		case <-ticker.C:
+		}
+
+		if err := result.Get(); err != nil {
+			// The job was deleted
+			return status.Deleted, nil
+		}
+	}
+}
+
+// sendLog sends what was written to a log of the job after its offset; only
+// whole lines are sent unless the job is finished
+func sendLog(j *jobs.Job, stream string, offsets *LogOffsets, finished bool, send func(LogChunk) bool) bool {
+	if j.LogPath == "" {
+		return true
+	}
+
+	path, offset := j.StdoutPath(), &offsets.Stdout
+	if stream == "stderr" {
+		path, offset = j.StderrPath(), &offsets.Stderr
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		// Not created yet
+		return true
+	}
+	defer f.Close()
+
+	// The logs are recreated when a job is requeued
+	if info, err := f.Stat(); err == nil && info.Size() < *offset {
+		*offset = 0
+	}
+
+	buf := make([]byte, logChunkSize)
+	for {
+		n, err := f.ReadAt(buf, *offset)
+		data := buf[:n]
+		if !finished && n < logChunkSize {
+			data = data[:bytes.LastIndexByte(data, '\n')+1]
+		}
+		if len(data) == 0 {
+			return true
+		}
+
+		*offset += int64(len(data))
+		if !send(LogChunk{Stream: stream, Data: data, Offsets: *offsets}) {
+			return false
+		}
+
+		if err == io.EOF || len(data) < n {
+			return true
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"jobd/domain/jobs"
+	"jobd/domain/status"
+	"jobd/errors"
+	"jobd/utils/testutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamJobLogs(t *testing.T) {
+	defer func(interval time.Duration) { LogPollInterval = interval }(LogPollInterval)
+	LogPollInterval = 10 * time.Millisecond
+
+	logDir := "./test-stream-job-logs"
+	_ = os.MkdirAll(logDir, 0755)
+	defer os.RemoveAll(logDir)
+
+	j := &jobs.Job{ID: "TestStreamJobLogs", Status: status.Running, LogPath: logDir}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.CleanupDB(t)
+
+	_ = os.WriteFile(j.StdoutPath(), []byte("first\nsecond"), 0644)
+
+	chunks := make(chan LogChunk, 10)
+	result := make(chan string, 1)
+	go func() {
+		last, _ := StreamJobLogs(context.Background(), *j, LogOffsets{}, func(chunk LogChunk) bool {
+			chunks <- chunk
+			return true
+		})
+		result <- last
+	}()
+
+	// Only whole lines are sent while the job runs
+	want := LogChunk{Stream: "stdout", Data: []byte("first\n"), Offsets: LogOffsets{Stdout: 6}}
+	select {
+	case got := <-chunks:
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("StreamJobLogs() sent %q, want %q", got.Data, want.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("StreamJobLogs() sent nothing")
+	}
+
+	// Whatever is left is sent once the job finishes
+	_ = os.WriteFile(j.StderrPath(), []byte("oops"), 0644)
+	j.Status = status.Success
+	j.Progress = &jobs.Progress{Percent: 100, Message: "done", Updated: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	progress := []byte(`{"percent":100,"message":"done","updated":"2026-01-01T00:00:00Z"}`)
+	testutil.WriteRecord(t, j.ID, j)
+
+	select {
+	case last := <-result:
+		if last != status.Success {
+			t.Errorf("StreamJobLogs() = %v, want %v", last, status.Success)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("StreamJobLogs() did not return")
+	}
+
+	close(chunks)
+	got := []LogChunk{}
+	for chunk := range chunks {
+		got = append(got, chunk)
+	}
+	wantRest := []LogChunk{
+		{Stream: "stdout", Data: []byte("second"), Offsets: LogOffsets{Stdout: 12}},
+		{Stream: "stderr", Data: []byte("oops"), Offsets: LogOffsets{Stdout: 12, Stderr: 4}},
+		{Stream: "progress", Data: progress, Offsets: LogOffsets{Stdout: 12, Stderr: 4}},
+	}
+	if !reflect.DeepEqual(got, wantRest) {
+		t.Errorf("StreamJobLogs() sent %v, want %v", got, wantRest)
+	}
+
+	// Resuming only sends what comes after the offsets
+	got = []LogChunk{}
+	_, _ = StreamJobLogs(context.Background(), *j, LogOffsets{Stdout: 6, Stderr: 4}, func(chunk LogChunk) bool {
+		got = append(got, chunk)
+		return true
+	})
+	wantResumed := []LogChunk{
+		{Stream: "stdout", Data: []byte("second"), Offsets: LogOffsets{Stdout: 12, Stderr: 4}},
+		{Stream: "progress", Data: progress, Offsets: LogOffsets{Stdout: 12, Stderr: 4}},
+	}
+	if !reflect.DeepEqual(got, wantResumed) {
+		t.Errorf("StreamJobLogs() resumed with %v, want %v", got, wantResumed)
+	}
+
+	// Unknown jobs
+	_, err := StreamJobLogs(context.Background(), jobs.Job{ID: "TestStreamJobLogs-missing"}, LogOffsets{}, nil)
+	if !reflect.DeepEqual(err, errors.NewNotFoundError("job not found")) {
+		t.Errorf("StreamJobLogs() error = %v", err)
+	}
+}
+
+func TestStreamJobLogsArray(t *testing.T) {
+	defer func(interval time.Duration) { LogPollInterval = interval }(LogPollInterval)
+	LogPollInterval = 10 * time.Millisecond
+
+	children := []string{"TestStreamJobLogsArray-1", "TestStreamJobLogsArray-2"}
+	for _, id := range children {
+		testutil.WriteRecord(t, id, &jobs.Job{ID: id, Status: status.Success})
+	}
+	j := &jobs.Job{ID: "TestStreamJobLogsArray", Status: status.Array, Array: &jobs.Array{Children: children}}
+	testutil.WriteRecord(t, j.ID, j)
+	testutil.CleanupDB(t)
+
+	// The stream ends once all the children are finished
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	last, err := StreamJobLogs(ctx, *j, LogOffsets{}, func(LogChunk) bool { return true })
+	if err != nil {
+		t.Fatalf("StreamJobLogs() error = %v", err)
+	}
+	if last != status.Success || ctx.Err() != nil {
+		t.Errorf("StreamJobLogs() = %v, want %v before the context expires", last, status.Success)
+	}
+}
@@ -0,0 +1,95 @@
+// Package services provides the services for the jobd application
+package services
+
+import (
+	"jobd/domain/jobs"
+	"jobd/domain/status"
+	"os"
+
+	"github.com/golang/glog"
+)
+
+// Recovery policies for jobs that were in-flight when jobd stopped
+const (
+	// RecoveryRequeue puts the jobs back in the queue
+	RecoveryRequeue = "requeue"
+	// RecoveryFail marks the jobs as FAILED
+	RecoveryFail = "fail"
+	// RecoveryReattach keeps following slurml jobs that are still running
+	// remotely and requeues the others
+	RecoveryReattach = "reattach"
+)
+
+// RecoveryPolicy is applied on startup to the jobs left in-flight
+var RecoveryPolicy = os.Getenv("RECOVERY_POLICY")
+
+func init() {
+	switch RecoveryPolicy {
+	case RecoveryRequeue, RecoveryFail, RecoveryReattach:
+	case "":
+		RecoveryPolicy = RecoveryReattach
+	default:
+		glog.Warningf("RECOVERY_POLICY=%q is not valid, using default `%s`", RecoveryPolicy, RecoveryReattach)
+		RecoveryPolicy = RecoveryReattach
+	}
+}
+
+// inFlight are the statuses of jobs that were being worked on
+var inFlight = []string{status.Claimed, status.Prepared, status.Running, status.Partial, status.Post_Processing}
+
+// RecoverJobs reconciles the jobs that were in-flight when jobd stopped, it must
+// run on startup before the scheduler. The jobs claimed by other instances
+// sharing the database are left to them
+func RecoverJobs() error {
+
+	stuckJobs, _ := jobs.ListByStatus(inFlight...)
+
+	for _, job := range stuckJobs {
+		if job.Owner != "" && job.Owner != jobs.Instance {
+			glog.Info("Not recovering job ", job.ID, ": it is owned by ", job.Owner)
+			continue
+		}
+		recoverJob(job)
+	}
+
+	return nil
+}
+
+// recoverJob applies the recovery policy to a job
+func recoverJob(j jobs.Job) {
+	previous := j.Status
+
+	// Whatever happens next, the working directory is not valid anymore
+	if jobs.DEBUG {
+		glog.Info("DEBUG is true, not deleting the job directory: ", j.Path)
+	} else if j.Path != "" {
+		_ = os.RemoveAll(j.Path)
+	}
+
+	remote := j.Slurml && j.SlurmID != 0
+
+	switch {
+	case RecoveryPolicy == RecoveryFail:
+		glog.Info("Recovering job ", j.ID, ": marking it as failed")
+		j.AddMessage("jobd restarted while the job was " + previous)
+		j.UpdateStatus(status.Failed)
+
+	case RecoveryPolicy == RecoveryReattach && remote:
+		glog.Info("Recovering job ", j.ID, ": reattaching to slurml job ", j.SlurmID)
+		j.AddMessage("reattached to the slurml job after jobd restarted")
+		j.UpdateStatus(status.Running)
+
+	default:
+		glog.Info("Recovering job ", j.ID, ": requeueing it")
+		if remote {
+			// It will be submitted again, do not leave the old one behind
+			if err := j.CancelOnSlurml(); err != nil {
+				glog.Warning("could not cancel job ", j.ID, " on slurml: ", err)
+			}
+			j.SlurmID = 0
+		}
+		j.Output = ""
+		j.AddMessage("requeued after jobd restarted while the job was " + previous)
+		j.UpdateStatus(status.Queued)
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"jobd/domain/jobs"
+	"jobd/domain/status"
+	"jobd/utils/testutil"
+	"os"
+	"testing"
+)
+
+func TestRecoverJobs(t *testing.T) {
+	defer func(p string) { RecoveryPolicy = p }(RecoveryPolicy)
+
+	testutil.CleanupDB(t)
+
+	tests := []struct {
+		name       string
+		policy     string
+		job        jobs.Job
+		wantStatus string
+		untouched  bool
+	}{
+		{
+			name:       "requeue-local",
+			policy:     RecoveryRequeue,
+			job:        jobs.Job{ID: "TestRecoverJobs-requeue-local", Status: status.Running},
+			wantStatus: status.Queued,
+		},
+		{
+			name:       "requeue-slurml",
+			policy:     RecoveryRequeue,
+			job:        jobs.Job{ID: "TestRecoverJobs-requeue-slurml", Status: status.Running, Slurml: true, SlurmID: 42},
+			wantStatus: status.Queued,
+		},
+		{
+			name:       "fail-local",
+			policy:     RecoveryFail,
+			job:        jobs.Job{ID: "TestRecoverJobs-fail-local", Status: status.Prepared},
+			wantStatus: status.Failed,
+		},
+		{
+			name:       "reattach-slurml",
+			policy:     RecoveryReattach,
+			job:        jobs.Job{ID: "TestRecoverJobs-reattach-slurml", Status: status.Partial, Slurml: true, SlurmID: 42},
+			wantStatus: status.Running,
+		},
+		{
+			name:       "reattach-local",
+			policy:     RecoveryReattach,
+			job:        jobs.Job{ID: "TestRecoverJobs-reattach-local", Status: status.Claimed},
+			wantStatus: status.Queued,
+		},
+		{
+			name:       "finished-untouched",
+			policy:     RecoveryFail,
+			job:        jobs.Job{ID: "TestRecoverJobs-finished", Status: status.Success},
+			wantStatus: status.Success,
+			untouched:  true,
+		},
+		{
+			name:       "own-instance",
+			policy:     RecoveryRequeue,
+			job:        jobs.Job{ID: "TestRecoverJobs-own-instance", Status: status.Running, Owner: jobs.Instance},
+			wantStatus: status.Queued,
+		},
+		{
+			name:       "other-instance",
+			policy:     RecoveryRequeue,
+			job:        jobs.Job{ID: "TestRecoverJobs-other-instance", Status: status.Running, Owner: jobs.Instance + "-other"},
+			wantStatus: status.Running,
+			untouched:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RecoveryPolicy = tt.policy
+
+			testDir := "./" + tt.job.ID
+			_ = os.MkdirAll(testDir, 0755)
+			defer os.RemoveAll(testDir)
+
+			j := tt.job
+			j.Path = testDir
+			testutil.WriteRecord(t, j.ID, j)
+
+			if err := RecoverJobs(); err != nil {
+				t.Errorf("RecoverJobs() error = %v", err)
+			}
+
+			got := &jobs.Job{ID: j.ID}
+			_ = got.Get()
+			if got.Status != tt.wantStatus {
+				t.Errorf("RecoverJobs() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+
+			_, errStat := os.Stat(testDir)
+			if cleaned := os.IsNotExist(errStat); cleaned == tt.untouched {
+				t.Errorf("RecoverJobs() cleaned the job directory = %v", cleaned)
+			}
+		})
+	}
+}
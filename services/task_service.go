@@ -2,15 +2,73 @@
 package services
 
 import (
+	"context"
 	"jobd/domain/jobs"
+	"jobd/domain/queue"
+	"jobd/domain/status"
+	"jobd/utils"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 )
 
-// RunTasks runs all queued jobs
+// MaxWorkers is the maximum number of local jobs executed at the same time
+var MaxWorkers = int(utils.GetEnvInt64("MAX_WORKERS", int64(runtime.NumCPU())))
+
+// ShutdownTimeout is how long in-flight jobs have to finish when jobd shuts down
+var ShutdownTimeout = time.Duration(utils.GetEnvInt64("SHUTDOWN_TIMEOUT", 30)) * time.Second
+
+// pool keeps count of the local jobs being executed
+var pool = struct {
+	sync.Mutex
+	running int
+}{}
+
+// dispatcher guards the dispatch of jobs so it does not overlap with a shutdown;
+// inflight tracks every job being executed
+var dispatcher = struct {
+	sync.Mutex
+	draining atomic.Bool
+	inflight sync.WaitGroup
+}{}
+
+// acquireWorker takes a slot in the pool, returns false if the pool is full
+func acquireWorker() bool {
+	pool.Lock()
+	defer pool.Unlock()
+	if pool.running >= MaxWorkers {
+		return false
+	}
+	pool.running++
+	return true
+}
+
+// releaseWorker frees a slot of the pool
+func releaseWorker() {
+	pool.Lock()
+	pool.running--
+	pool.Unlock()
+}
+
+// RunTasks runs queued jobs; each one is claimed first so it is never executed
+// twice. Local jobs are limited by the size of the worker pool, the ones that do
+// not fit are left QUEUED for the next round
 func RunTasks() error {
 
+	dispatcher.Lock()
+	defer dispatcher.Unlock()
+
+	if dispatcher.draining.Load() {
+		return nil
+	}
+
+	resolveDependencies()
+	promoteScheduled()
+
 	queuedJobs, _ := jobs.ListQueued()
 	// if err != nil {
 	// 	log.Println(err)
@@ -18,7 +76,38 @@ func RunTasks() error {
 	// }
 
 	for _, job := range queuedJobs {
+		// Waiting before being retried
+		if !job.Ready() {
+			continue
+		}
+
+		// Slurml jobs are only submitted from here, they do not take a worker
+		if job.Slurml {
+			if !job.Claim() {
+				continue
+			}
+			dispatcher.inflight.Add(1)
+			go func(j jobs.Job) {
+				defer dispatcher.inflight.Done()
+				_ = j.Execute()
+			}(job)
+			continue
+		}
+
+		if !acquireWorker() {
+			continue
+		}
+
+		// Claiming fails if the job was already taken, e.g. by another replica
+		if !job.Claim() {
+			releaseWorker()
+			continue
+		}
+
+		dispatcher.inflight.Add(1)
 		go func(j jobs.Job) {
+			defer dispatcher.inflight.Done()
+			defer releaseWorker()
 			_ = j.Execute()
 		}(job)
 	}
@@ -26,6 +115,88 @@ func RunTasks() error {
 	return nil
 }
 
+// promoteScheduled queues the scheduled jobs whose start time was reached
+func promoteScheduled() {
+	scheduledJobs, _ := jobs.ListByStatus(status.Scheduled)
+	for _, job := range scheduledJobs {
+		if job.Ready() && job.Promote() {
+			glog.Info("Job ", job.ID, " reached its start time, queueing it")
+		}
+	}
+}
+
+// resolveDependencies releases, fails or cancels the jobs waiting for their parents
+func resolveDependencies() {
+	waitingJobs, _ := jobs.ListByStatus(status.Waiting)
+	for _, job := range waitingJobs {
+		if next := job.Resolve(); next != "" {
+			glog.Info("Job ", job.ID, " is done waiting for its parents: ", next)
+		}
+	}
+}
+
+// StopAccepting makes jobd refuse new jobs and stop dispatching queued ones
+func StopAccepting() {
+	// Wait for a dispatch in progress so nothing is started after this
+	dispatcher.Lock()
+	dispatcher.draining.Store(true)
+	dispatcher.Unlock()
+}
+
+// IsDraining reports if jobd is shutting down
+func IsDraining() bool {
+	return dispatcher.draining.Load()
+}
+
+// Drain waits for the jobs being executed to finish; the ones still running when
+// `ctx` is done are interrupted and requeued, so they run again on the next start
+func Drain(ctx context.Context) {
+	StopAccepting()
+
+	done := make(chan struct{})
+	go func() {
+		dispatcher.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		glog.Info("All jobs finished")
+		return
+	case <-ctx.Done():
+	}
+
+	glog.Warning("Shutdown deadline reached, interrupting the running jobs")
+	jobs.CancelAll(jobs.ErrInterrupted)
+
+	select {
+	case <-done:
+	case <-time.After(jobs.KillGrace + 5*time.Second):
+		glog.Error("Some jobs did not stop in time")
+	}
+}
+
+// GetQueueStats returns the size of the worker pool, how much of it is in use
+// and how many jobs are waiting
+func GetQueueStats() queue.Stats {
+	queuedJobs, _ := jobs.ListQueued()
+	scheduledJobs, _ := jobs.ListByStatus(status.Scheduled)
+	waitingJobs, _ := jobs.ListByStatus(status.Waiting)
+	heldJobs, _ := jobs.ListByStatus(status.Held)
+
+	pool.Lock()
+	defer pool.Unlock()
+
+	return queue.Stats{
+		Workers:   MaxWorkers,
+		Running:   pool.running,
+		Queued:    len(queuedJobs),
+		Scheduled: len(scheduledJobs),
+		Waiting:   len(waitingJobs),
+		Held:      len(heldJobs),
+	}
+}
+
 // UpdateSlurmlJobs updates all jobs that are running on Slurml
 func UpdateSlurmlJobs() error {
 
@@ -37,23 +208,48 @@ func UpdateSlurmlJobs() error {
 
 	for _, job := range slurmJobs {
 		go func(j jobs.Job) {
-			_ = j.GetFromSlurml()
+			j.GetFromSlurml()
 		}(job)
 	}
 
 	return nil
 }
 
-// ClearOldJobs clears jobs that are older than 2 days
+// ClearOldJobs clears finished jobs that are older than 2 days, unless a job
+// still waits for them or takes its input from them; array jobs go once all their children are finished
 func ClearOldJobs() error {
 
 	cutoff := time.Now().AddDate(0, 0, -2)
 
 	oldJobs, _ := jobs.ListOld(cutoff)
 
+	// The parents and input sources of unfinished jobs are kept until they do
+	// not need them anymore
+	needed := map[string]bool{}
+	unfinishedJobs, _ := jobs.ListUnfinished()
+	for _, j := range unfinishedJobs {
+		for _, id := range j.DependsOn {
+			needed[id] = true
+		}
+		if j.InputFrom != "" {
+			needed[j.InputFrom] = true
+		}
+	}
+
 	for _, job := range oldJobs {
+		// The status of an array job is the one of its children
+		if job.Array != nil {
+			job.Aggregate()
+		}
+		if !status.IsTerminal(job.Status) || needed[job.ID] {
+			continue
+		}
+
 		go func(j jobs.Job) {
 			glog.Info("Deleting job ", j.ID, " older than ", cutoff)
+			if j.LogPath != "" {
+				_ = os.RemoveAll(j.LogPath)
+			}
 			_ = j.Delete()
 		}(job)
 	}
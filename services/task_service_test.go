@@ -1,21 +1,19 @@
 package services
 
 import (
+	"context"
 	"jobd/datasource/db"
 	"jobd/domain/jobs"
+	"jobd/domain/queue"
 	"jobd/domain/status"
+	"jobd/errors"
+	"jobd/utils/testutil"
 	"os"
+	"reflect"
 	"testing"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
-func init() {
-	_ = db.InitDB()
-	gin.SetMode(gin.TestMode)
-}
-
 func TestRunTasks(t *testing.T) {
 	// Add some queued jobs to the database
 	j := &jobs.Job{ID: "TestRunTasks", Status: status.Queued, Input: "UEsDBAoAAAAAAKVdUFYAAAAAAAAAAAAAAAAGABwAcnVuLnNoVVQJAAM1Ce5jNQnuY3V4CwABBPUBAAAEAAAAAFBLAQIeAwoAAAAAAKVdUFYAAAAAAAAAAAAAAAAGABgAAAAAAAAAAACkgQAAAABydW4uc2hVVAUAAzUJ7mN1eAsAAQT1AQAABAAAAABQSwUGAAAAAAEAAQBMAAAAQAAAAAAA"}
@@ -29,6 +27,9 @@ func TestRunTasks(t *testing.T) {
 	// // Remove the database after the test
 	defer os.RemoveAll(db.NAME)
 
+	// Let the dispatched job finish before cleaning up
+	defer waitForIdlePool(t)
+
 	tests := []struct {
 		name    string
 		wantErr bool
@@ -48,27 +49,217 @@ func TestRunTasks(t *testing.T) {
 }
 
 func TestClearOldJobs(t *testing.T) {
-	// Add some queued jobs to the database
-	j := &jobs.Job{ID: "TestClearOldJobs", LastUpdated: time.Now().AddDate(0, 0, -99)}
-	_ = db.Client.Write(db.NAME, j.ID, j)
+	old := time.Now().AddDate(0, 0, -99)
+
+	for _, j := range []*jobs.Job{
+		{ID: "TestClearOldJobs-success", Status: status.Success, LastUpdated: old},
+		{ID: "TestClearOldJobs-recent", Status: status.Success, LastUpdated: time.Now()},
+		{ID: "TestClearOldJobs-scheduled", Status: status.Scheduled, LastUpdated: old},
+		{ID: "TestClearOldJobs-held", Status: status.Held, LastUpdated: old},
+		{ID: "TestClearOldJobs-parent", Status: status.Success, LastUpdated: old},
+		{ID: "TestClearOldJobs-child", Status: status.Waiting, LastUpdated: old, DependsOn: []string{"TestClearOldJobs-parent", "TestClearOldJobs-held"}},
+		{ID: "TestClearOldJobs-source", Status: status.Success, LastUpdated: old},
+		{ID: "TestClearOldJobs-consumer", Status: status.Held, LastUpdated: old, InputFrom: "TestClearOldJobs-source"},
+		{ID: "TestClearOldJobs-queued-source", Status: status.Success, LastUpdated: old},
+		{ID: "TestClearOldJobs-queued-consumer", Status: status.Queued, LastUpdated: time.Now(), InputFrom: "TestClearOldJobs-queued-source"},
+		{ID: "TestClearOldJobs-array", Status: status.Array, LastUpdated: old, Array: &jobs.Array{Children: []string{"TestClearOldJobs-success", "TestClearOldJobs-scheduled"}}},
+		{ID: "TestClearOldJobs-done-array", Status: status.Array, LastUpdated: old, Array: &jobs.Array{Children: []string{"TestClearOldJobs-recent"}}},
+	} {
+		_ = db.Client.Write(db.NAME, j.ID, j)
+	}
 
 	// Delete the database after the test
 	defer os.RemoveAll(db.NAME)
 
+	if err := ClearOldJobs(); err != nil {
+		t.Errorf("ClearOldJobs() error = %v", err)
+	}
+
 	tests := []struct {
-		name    string
-		wantErr bool
+		id   string
+		kept bool
 	}{
-		{
-			name:    "clear old jobs",
-			wantErr: false,
-		},
+		// The deleted ones first, the others would have been deleted meanwhile
+		{id: "TestClearOldJobs-success", kept: false},
+		{id: "TestClearOldJobs-done-array", kept: false},
+		{id: "TestClearOldJobs-recent", kept: true},
+		{id: "TestClearOldJobs-scheduled", kept: true},
+		{id: "TestClearOldJobs-held", kept: true},
+		{id: "TestClearOldJobs-parent", kept: true},
+		{id: "TestClearOldJobs-child", kept: true},
+		{id: "TestClearOldJobs-array", kept: true},
+		{id: "TestClearOldJobs-source", kept: true},
+		{id: "TestClearOldJobs-consumer", kept: true},
+		{id: "TestClearOldJobs-queued-source", kept: true},
 	}
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := ClearOldJobs(); (err != nil) != tt.wantErr {
-				t.Errorf("ClearOldJobs() error = %v, wantErr %v", err, tt.wantErr)
+		t.Run(tt.id, func(t *testing.T) {
+			// The jobs are deleted in the background
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				kept := (&jobs.Job{ID: tt.id}).Get() == nil
+				if kept == tt.kept || time.Now().After(deadline) {
+					if kept != tt.kept {
+						t.Errorf("job %v kept = %v, want %v", tt.id, kept, tt.kept)
+					}
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
 			}
 		})
 	}
 }
+
+// waitForIdlePool waits for the jobs started by other tests to finish
+func waitForIdlePool(t *testing.T) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		pool.Lock()
+		running := pool.running
+		pool.Unlock()
+		if running == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker pool still busy with %d jobs", running)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRunTasksFullPool(t *testing.T) {
+	waitForIdlePool(t)
+	defer func(n int) { MaxWorkers = n }(MaxWorkers)
+	MaxWorkers = 0
+
+	j := &jobs.Job{ID: "TestRunTasksFullPool", Status: status.Queued}
+	testutil.WriteRecord(t, j.ID, j)
+
+	testutil.CleanupDB(t)
+
+	if err := RunTasks(); err != nil {
+		t.Errorf("RunTasks() error = %v", err)
+	}
+
+	// Nothing can be dispatched, the job stays queued
+	got := &jobs.Job{ID: j.ID}
+	_ = got.Get()
+	if got.Status != status.Queued {
+		t.Errorf("RunTasks() job status = %v, want %v", got.Status, status.Queued)
+	}
+
+	stats := GetQueueStats()
+	want := queue.Stats{Workers: 0, Running: 0, Queued: 1}
+	if stats != want {
+		t.Errorf("GetQueueStats() = %v, want %v", stats, want)
+	}
+}
+
+func TestRunTasksScheduled(t *testing.T) {
+	waitForIdlePool(t)
+	defer func(n int) { MaxWorkers = n }(MaxWorkers)
+	MaxWorkers = 0
+
+	due := &jobs.Job{ID: "TestRunTasksScheduled-due", Status: status.Scheduled, NotBefore: time.Now().Add(-time.Second)}
+	testutil.WriteRecord(t, due.ID, due)
+	later := &jobs.Job{ID: "TestRunTasksScheduled-later", Status: status.Scheduled, NotBefore: time.Now().Add(time.Hour)}
+	testutil.WriteRecord(t, later.ID, later)
+
+	testutil.CleanupDB(t)
+
+	if err := RunTasks(); err != nil {
+		t.Errorf("RunTasks() error = %v", err)
+	}
+
+	for id, want := range map[string]string{due.ID: status.Queued, later.ID: status.Scheduled} {
+		got := &jobs.Job{ID: id}
+		_ = got.Get()
+		if got.Status != want {
+			t.Errorf("RunTasks() job %v status = %v, want %v", id, got.Status, want)
+		}
+	}
+
+	stats := GetQueueStats()
+	want := queue.Stats{Workers: 0, Running: 0, Queued: 1, Scheduled: 1}
+	if stats != want {
+		t.Errorf("GetQueueStats() = %v, want %v", stats, want)
+	}
+}
+
+func TestAcquireWorker(t *testing.T) {
+	waitForIdlePool(t)
+	defer func(n int) { MaxWorkers = n }(MaxWorkers)
+	MaxWorkers = 1
+
+	if !acquireWorker() {
+		t.Errorf("acquireWorker() = false, want true")
+	}
+	if acquireWorker() {
+		t.Errorf("acquireWorker() on a full pool = true, want false")
+	}
+	releaseWorker()
+	if !acquireWorker() {
+		t.Errorf("acquireWorker() after release = false, want true")
+	}
+	releaseWorker()
+}
+
+// waitForStatus waits for a job to reach the given status
+func waitForStatus(t *testing.T, id, s string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got := &jobs.Job{ID: id}
+		_ = got.Get()
+		if got.Status == s {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not reach status %s, it is %s", id, s, got.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	waitForIdlePool(t)
+	defer dispatcher.draining.Store(false)
+
+	testDir := "./test-drain"
+	defer os.RemoveAll(testDir)
+
+	testutil.CleanupDB(t)
+
+	j := &jobs.Job{
+		ID:     "TestDrain",
+		Status: status.Queued,
+		Path:   testDir,
+		Input:  testutil.ZipBase64(t, map[string]string{"run.sh": "#!/bin/bash\nsleep 60 &\nwait"}),
+	}
+	testutil.WriteRecord(t, j.ID, j)
+
+	_ = RunTasks()
+	waitForStatus(t, j.ID, status.Running)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	Drain(ctx)
+
+	// The job did not finish in time, it goes back to the queue
+	got := &jobs.Job{ID: j.ID}
+	_ = got.Get()
+	if got.Status != status.Queued {
+		t.Errorf("Drain() job status = %v, want %v", got.Status, status.Queued)
+	}
+
+	// Nothing is dispatched nor accepted anymore
+	_ = RunTasks()
+	_ = got.Get()
+	if got.Status != status.Queued {
+		t.Errorf("RunTasks() while draining job status = %v, want %v", got.Status, status.Queued)
+	}
+
+	_, err := CreateJob(jobs.Job{ID: "TestDrain-new"})
+	if !reflect.DeepEqual(err, errors.NewServiceUnavailableError("jobd is shutting down, not accepting new jobs")) {
+		t.Errorf("CreateJob() while draining error = %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/golang/glog"
+)
+
+// GetEnvInt64 reads an integer from the environment, falling back to `def`
+func GetEnvInt64(name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		glog.Warningf("%s=%q is not a valid integer, using default `%d`", name, v, def)
+		return def
+	}
+	return i
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestGetEnvInt64(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		def   int64
+		want  int64
+	}{
+		{
+			name:  "unset",
+			value: "",
+			def:   10,
+			want:  10,
+		},
+		{
+			name:  "valid",
+			value: "42",
+			def:   10,
+			want:  42,
+		},
+		{
+			name:  "invalid",
+			value: "forty-two",
+			def:   10,
+			want:  10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JOBD_TEST_INT", tt.value)
+			if got := GetEnvInt64("JOBD_TEST_INT", tt.def); got != tt.want {
+				t.Errorf("GetEnvInt64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
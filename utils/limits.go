@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// Limits are the resources a script can use, 0 means unlimited
+type Limits struct {
+	// MemoryMB is the maximum memory in megabytes
+	MemoryMB int64 `json:"memory_mb"`
+	// CPUSeconds is the maximum CPU time in seconds
+	CPUSeconds int64 `json:"cpu_seconds"`
+	// FileSizeMB is the maximum size in megabytes of any file written
+	FileSizeMB int64 `json:"file_size_mb"`
+	// Processes is the maximum number of processes, only enforced with a cgroup:
+	// RLIMIT_NPROC counts every process of the user and root ignores it
+	Processes int64 `json:"processes"`
+}
+
+// IsZero reports if no limit is set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// LimitError is returned when a script is stopped for going over one of its limits
+type LimitError struct {
+	Resource string
+}
+
+func (e *LimitError) Error() string {
+	return "killed: " + e.Resource + " limit exceeded"
+}
+
+const mb = 1024 * 1024
+
+// cgroup is a cgroup v2 group created for a single script
+type cgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newCgroup creates a group under `parent` enforcing the memory and process limits,
+// `parent` must be a cgroup v2 directory delegated to jobd with the `memory` and
+// `pids` controllers enabled for its children
+func newCgroup(parent string, l Limits) (*cgroup, error) {
+	path := filepath.Join(parent, "jobd-"+UniqueID(12))
+	err := os.Mkdir(path, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cgroup{path: path}
+
+	if l.MemoryMB > 0 {
+		err = c.write("memory.max", strconv.FormatInt(l.MemoryMB*mb, 10))
+		if err != nil {
+			c.remove()
+			return nil, err
+		}
+		// Do not let it swap instead of being killed
+		_ = c.write("memory.swap.max", "0")
+	}
+
+	if l.Processes > 0 {
+		err = c.write("pids.max", strconv.FormatInt(l.Processes, 10))
+		if err != nil {
+			c.remove()
+			return nil, err
+		}
+	}
+
+	c.dir, err = os.Open(path)
+	if err != nil {
+		c.remove()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *cgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(c.path, file), []byte(value), 0644)
+}
+
+// event returns the counter `key` of an events file of the group
+func (c *cgroup) event(file, key string) int64 {
+	f, err := os.Open(filepath.Join(c.path, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.ParseInt(fields[1], 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// remove kills whatever is left in the group and deletes it
+func (c *cgroup) remove() {
+	if c.dir != nil {
+		c.dir.Close()
+	}
+	_ = c.write("cgroup.kill", "1")
+
+	// The group can only be removed once its processes are gone
+	for i := 0; i < 50; i++ {
+		err := os.Remove(c.path)
+		if err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	glog.Warning("could not remove cgroup ", c.path)
+}
+
+// applyRlimits sets the limits of a started process, they are inherited by every
+// process it creates; memory is left to the cgroup when there is one and the
+// process count has no per-process limit, only the cgroup enforces it
+func applyRlimits(pid int, l Limits, withCgroup bool) error {
+	set := func(resource int, soft, hard uint64) error {
+		return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: soft, Max: hard}, nil)
+	}
+
+	if l.MemoryMB > 0 && !withCgroup {
+		v := uint64(l.MemoryMB * mb)
+		if err := set(unix.RLIMIT_AS, v, v); err != nil {
+			return err
+		}
+	}
+
+	if l.CPUSeconds > 0 {
+		// SIGXCPU at the soft limit, SIGKILL at the hard one
+		v := uint64(l.CPUSeconds)
+		if err := set(unix.RLIMIT_CPU, v, v+5); err != nil {
+			return err
+		}
+	}
+
+	if l.FileSizeMB > 0 {
+		v := uint64(l.FileSizeMB * mb)
+		if err := set(unix.RLIMIT_FSIZE, v, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// limitViolation tells if a script that exited with an error went over one of
+// its limits; a shell reports a child killed by signal N with exit code 128+N
+func limitViolation(state *os.ProcessState, l Limits, c *cgroup) error {
+	if state == nil || l.IsZero() {
+		return nil
+	}
+
+	if c != nil {
+		if l.MemoryMB > 0 && c.event("memory.events", "oom_kill") > 0 {
+			return &LimitError{Resource: "memory"}
+		}
+		if l.Processes > 0 && c.event("pids.events", "max") > 0 {
+			return &LimitError{Resource: "process count"}
+		}
+	}
+
+	var sig syscall.Signal
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	switch {
+	case ok && ws.Signaled():
+		sig = ws.Signal()
+	case state.ExitCode() > 128:
+		sig = syscall.Signal(state.ExitCode() - 128)
+	default:
+		return nil
+	}
+
+	switch {
+	case sig == syscall.SIGXCPU && l.CPUSeconds > 0:
+		return &LimitError{Resource: "cpu time"}
+	case sig == syscall.SIGKILL && l.CPUSeconds > 0 && state.UserTime()+state.SystemTime() >= time.Duration(l.CPUSeconds)*time.Second:
+		return &LimitError{Resource: "cpu time"}
+	case sig == syscall.SIGXFSZ && l.FileSizeMB > 0:
+		return &LimitError{Resource: "file size"}
+	case (sig == syscall.SIGSEGV || sig == syscall.SIGABRT || sig == syscall.SIGBUS) && l.MemoryMB > 0 && c == nil:
+		// Allocations fail once the address space limit is reached
+		return &LimitError{Resource: "memory"}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestScript_RunLimits(t *testing.T) {
+	testDir := "/tmp/jobd-test-limits"
+	_ = os.MkdirAll(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	_ = os.WriteFile(testDir+"/busy.sh", []byte("#!/bin/bash\nwhile true; do :; done"), 0755)
+	_ = os.WriteFile(testDir+"/write.sh", []byte("#!/bin/bash\nhead -c 3000000 /dev/zero > big"), 0755)
+	_ = os.WriteFile(testDir+"/fails.sh", []byte("#!/bin/bash\nexit 3"), 0755)
+	_ = os.WriteFile(testDir+"/forks.sh", []byte("#!/bin/bash\nsleep 0.1 & sleep 0.1 & wait"), 0755)
+
+	tests := []struct {
+		name         string
+		script       string
+		limits       Limits
+		wantResource string
+		wantErr      bool
+	}{
+		{
+			name:         "cpu-time",
+			script:       "busy.sh",
+			limits:       Limits{CPUSeconds: 1},
+			wantResource: "cpu time",
+			wantErr:      true,
+		},
+		{
+			name:         "file-size",
+			script:       "write.sh",
+			limits:       Limits{FileSizeMB: 1},
+			wantResource: "file size",
+			wantErr:      true,
+		},
+		{
+			name:    "regular-failure",
+			script:  "fails.sh",
+			limits:  Limits{FileSizeMB: 1},
+			wantErr: true,
+		},
+		{
+			// Only a cgroup limits the processes
+			name:    "processes-without-cgroup",
+			script:  "forks.sh",
+			limits:  Limits{Processes: 1},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			s := Script{Dir: testDir, Command: "./" + tt.script, Limits: tt.limits, Grace: time.Second}
+			err := s.Run(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Script.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var limitErr *LimitError
+			gotResource := ""
+			if errors.As(err, &limitErr) {
+				gotResource = limitErr.Resource
+			}
+			if gotResource != tt.wantResource {
+				t.Errorf("Script.Run() exceeded limit = %q, want %q (error = %v)", gotResource, tt.wantResource, err)
+			}
+		})
+	}
+}
+
+func TestLimitError_Error(t *testing.T) {
+	err := &LimitError{Resource: "memory"}
+	if got := err.Error(); got != "killed: memory limit exceeded" {
+		t.Errorf("LimitError.Error() = %v", got)
+	}
+}
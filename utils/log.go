@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"io"
+	"strconv"
+)
+
+// CappedWriter writes to an underlying writer until a size limit is reached,
+// everything after that is discarded and a truncation note is written once
+type CappedWriter struct {
+	W         io.Writer
+	Limit     int64
+	written   int64
+	truncated bool
+}
+
+// NewCappedWriter returns a writer that stops writing to `w` after `limit` bytes,
+// a limit <= 0 means no limit
+func NewCappedWriter(w io.Writer, limit int64) *CappedWriter {
+	return &CappedWriter{W: w, Limit: limit}
+}
+
+// Write implements io.Writer; it never fails because of the limit so the
+// process writing to it is not interrupted
+func (c *CappedWriter) Write(p []byte) (int, error) {
+	if c.Limit <= 0 {
+		return c.W.Write(p)
+	}
+
+	if c.truncated {
+		return len(p), nil
+	}
+
+	remaining := c.Limit - c.written
+	if int64(len(p)) <= remaining {
+		n, err := c.W.Write(p)
+		c.written += int64(n)
+		return len(p), err
+	}
+
+	n, err := c.W.Write(p[:remaining])
+	c.written += int64(n)
+	c.truncated = true
+	_, _ = io.WriteString(c.W, "\n[jobd] log truncated after "+strconv.FormatInt(c.Limit, 10)+" bytes\n")
+	return len(p), err
+}
+
+// Truncated reports if the limit was reached
+func (c *CappedWriter) Truncated() bool {
+	return c.truncated
+}
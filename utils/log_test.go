@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCappedWriter_Write(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int64
+		writes        []string
+		want          string
+		wantTruncated bool
+	}{
+		{
+			name:   "no-limit",
+			limit:  0,
+			writes: []string{"hello ", "world"},
+			want:   "hello world",
+		},
+		{
+			name:   "under-limit",
+			limit:  20,
+			writes: []string{"hello ", "world"},
+			want:   "hello world",
+		},
+		{
+			name:          "over-limit",
+			limit:         8,
+			writes:        []string{"hello ", "world", "again"},
+			want:          "hello wo\n[jobd] log truncated after 8 bytes\n",
+			wantTruncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewCappedWriter(&buf, tt.limit)
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Errorf("CappedWriter.Write() error = %v", err)
+				}
+				if n != len(s) {
+					t.Errorf("CappedWriter.Write() = %v, want %v", n, len(s))
+				}
+			}
+			if buf.String() != tt.want {
+				t.Errorf("CappedWriter.Write() wrote %q, want %q", buf.String(), tt.want)
+			}
+			if w.Truncated() != tt.wantTruncated {
+				t.Errorf("CappedWriter.Truncated() = %v, want %v", w.Truncated(), tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Script describes a command executed inside a job directory
+type Script struct {
+	Dir string
+	// Command is the program to execute, paths are relative to Dir
+	Command string
+	Args    []string
+	// Env is the environment of the script, when nil it inherits the one of jobd
+	Env    []string
+	Stdout io.Writer
+	Stderr io.Writer
+	// Grace is how long the process group has to exit after SIGTERM before it is killed
+	Grace time.Duration
+	// Limits are the resources the script can use
+	Limits Limits
+	// Cgroup is a cgroup v2 directory under which a group is created to enforce
+	// the limits; when empty only rlimits are used
+	Cgroup string
+	// Credential is the user and group the script runs as, the ones of jobd when nil
+	Credential *syscall.Credential
+	// Info is filled in by Run once the script exits
+	Info *ProcessInfo
+}
+
+// startGate holds the script until its limits are in place, the shell waits for
+// fd 3 to be closed and then replaces itself with the script
+const startGate = `read _ <&3; exec 3<&- "$@"`
+
+// Run executes the script in its own process group; if `ctx` is done before the
+// script exits the whole group gets SIGTERM, then SIGKILL once the grace period is over
+func (s *Script) Run(ctx context.Context) error {
+
+	cmd := exec.Command(s.Command, s.Args...)
+
+	// The limits are applied after the process starts, so it must not run
+	// anything before they are
+	var gate *os.File
+	if !s.Limits.IsZero() {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		defer w.Close()
+		gate = w
+		cmd = exec.Command("/bin/sh", append([]string{"-c", startGate, "jobd", s.Command}, s.Args...)...)
+		cmd.ExtraFiles = []*os.File{r}
+	}
+
+	cmd.Dir = s.Dir
+	cmd.Env = s.Env
+	cmd.Stdout = s.Stdout
+	cmd.Stderr = s.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: s.Credential}
+
+	var cg *cgroup
+	if s.Cgroup != "" && (s.Limits.MemoryMB > 0 || s.Limits.Processes > 0) {
+		var err error
+		cg, err = newCgroup(s.Cgroup, s.Limits)
+		if err != nil {
+			glog.Warning("could not create a cgroup, using rlimits only: ", err)
+		} else {
+			defer cg.remove()
+			// Start the script directly inside the group
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = int(cg.dir.Fd())
+		}
+	}
+	if cg == nil && s.Limits.Processes > 0 {
+		glog.Warning("the process limit needs a cgroup, it is not enforced")
+	}
+
+	start := time.Now()
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		s.Info = newProcessInfo(cmd.ProcessState, time.Since(start))
+		done <- err
+	}()
+
+	if !s.Limits.IsZero() {
+		err = applyRlimits(cmd.Process.Pid, s.Limits, cg != nil)
+		if err != nil {
+			glog.Error("could not apply the limits, stopping the script: ", err)
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			<-done
+			return err
+		}
+		gate.Close()
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if violation := limitViolation(cmd.ProcessState, s.Limits, cg); violation != nil {
+				return violation
+			}
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	// With Setpgid the group id is the pid of the script
+	pgid := cmd.Process.Pid
+	_ = syscall.Kill(-pgid, syscall.SIGTERM)
+
+	select {
+	case <-done:
+	case <-time.After(s.Grace):
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		<-done
+	}
+
+	return context.Cause(ctx)
+}
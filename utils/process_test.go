@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestScript_Run(t *testing.T) {
+	testDir := "/tmp/jobd-test-script"
+	_ = os.MkdirAll(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	// A script that finishes on its own
+	_ = os.WriteFile(testDir+"/quick.sh", []byte("#!/bin/bash\nexit 0"), 0755)
+	// A script that spawns a child and waits for it
+	_ = os.WriteFile(testDir+"/slow.sh", []byte("#!/bin/bash\nsleep 60 &\nwait"), 0755)
+	// A script that ignores SIGTERM
+	_ = os.WriteFile(testDir+"/stubborn.sh", []byte("#!/bin/bash\ntrap '' TERM\nsleep 60 &\nwait"), 0755)
+
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		wantErr error
+	}{
+		{
+			name:    "finishes",
+			script:  "quick.sh",
+			timeout: 10 * time.Second,
+			wantErr: nil,
+		},
+		{
+			name:    "terminated",
+			script:  "slow.sh",
+			timeout: 100 * time.Millisecond,
+			wantErr: errStop,
+		},
+		{
+			name:    "killed",
+			script:  "stubborn.sh",
+			timeout: 100 * time.Millisecond,
+			wantErr: errStop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeoutCause(context.Background(), tt.timeout, errStop)
+			defer cancel()
+
+			s := Script{Dir: testDir, Command: "./" + tt.script, Grace: 200 * time.Millisecond}
+
+			start := time.Now()
+			err := s.Run(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Script.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Script.Run() took %v, the process group was not stopped", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Package testutil holds the helpers shared by the tests of the other packages
+package testutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"jobd/datasource/db"
+	"os"
+	"testing"
+)
+
+// ZipBase64 builds a base64 encoded zip with the given files
+func ZipBase64(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(w, content)
+	}
+	zipWriter.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// WriteRecord saves `record` in the database under `id`
+func WriteRecord(t *testing.T, id string, record any) {
+	t.Helper()
+
+	if err := db.Client.Write(db.NAME, id, record); err != nil {
+		t.Fatalf("could not write record %s: %v", id, err)
+	}
+}
+
+// CleanupDB deletes the database once the test is done
+func CleanupDB(t *testing.T) {
+	t.Cleanup(func() {
+		_ = os.RemoveAll(db.NAME)
+	})
+}
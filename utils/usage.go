@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProcessInfo describes how a process ended and the resources it used
+type ProcessInfo struct {
+	// ExitCode is the exit status of the process, -1 when it was killed by a signal
+	ExitCode int `json:"exit_code"`
+	// Signal is the name of the signal that terminated the process, if any
+	Signal string `json:"signal,omitempty"`
+	// WallTime is how long the process ran, in seconds
+	WallTime float64 `json:"wall_time"`
+	// UserTime is the user CPU time of the process and its children, in seconds
+	UserTime float64 `json:"user_time"`
+	// SystemTime is the system CPU time of the process and its children, in seconds
+	SystemTime float64 `json:"system_time"`
+	// MaxRSSKB is the maximum resident set size of the process, or its largest child, in kilobytes
+	MaxRSSKB int64 `json:"max_rss_kb"`
+}
+
+// newProcessInfo collects the information of a process that exited after `wall`
+func newProcessInfo(state *os.ProcessState, wall time.Duration) *ProcessInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &ProcessInfo{
+		ExitCode:   state.ExitCode(),
+		WallTime:   wall.Seconds(),
+		UserTime:   state.UserTime().Seconds(),
+		SystemTime: state.SystemTime().Seconds(),
+	}
+
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		info.Signal = unix.SignalName(ws.Signal())
+	}
+
+	// On Linux ru_maxrss is already in kilobytes
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		info.MaxRSSKB = ru.Maxrss
+	}
+
+	return info
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestScript_RunInfo(t *testing.T) {
+	testDir := "/tmp/jobd-test-script-info"
+	_ = os.MkdirAll(testDir, 0755)
+	defer os.RemoveAll(testDir)
+
+	_ = os.WriteFile(testDir+"/exit.sh", []byte("#!/bin/bash\nexit 2"), 0755)
+	_ = os.WriteFile(testDir+"/crash.sh", []byte("#!/bin/bash\nkill -SEGV $$"), 0755)
+
+	tests := []struct {
+		name         string
+		script       string
+		wantExitCode int
+		wantSignal   string
+	}{
+		{
+			name:         "exit code",
+			script:       "exit.sh",
+			wantExitCode: 2,
+		},
+		{
+			name:         "signal",
+			script:       "crash.sh",
+			wantExitCode: -1,
+			wantSignal:   "SIGSEGV",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Script{Dir: testDir, Command: "./" + tt.script}
+			_ = s.Run(context.Background())
+
+			if s.Info == nil {
+				t.Fatalf("Script.Info = nil")
+			}
+			if s.Info.ExitCode != tt.wantExitCode {
+				t.Errorf("Script.Info.ExitCode = %v, want %v", s.Info.ExitCode, tt.wantExitCode)
+			}
+			if s.Info.Signal != tt.wantSignal {
+				t.Errorf("Script.Info.Signal = %v, want %v", s.Info.Signal, tt.wantSignal)
+			}
+			if s.Info.WallTime <= 0 || s.Info.MaxRSSKB <= 0 {
+				t.Errorf("Script.Info = %+v, want the usage of the script", s.Info)
+			}
+		})
+	}
+}
@@ -4,11 +4,11 @@ package utils
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"io"
 	"math/rand"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/golang/glog"
@@ -135,20 +135,41 @@ func Zip(srcDir string) ([]byte, error) {
 	return zipBytes, nil
 }
 
-// RunScript runs a script in a directory
-func RunScript(dir, script string) error {
+// ZipFiles compresses the given files of a directory, named by their paths
+// relative to it; files that disappeared meanwhile are left out
+func ZipFiles(srcDir string, files []string) ([]byte, error) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
 
-	var out bytes.Buffer
+	for _, name := range files {
+		file, err := os.Open(filepath.Join(srcDir, name))
+		if err != nil {
+			continue
+		}
 
-	// Run the job
-	cmd := exec.Command("./" + script)
-	cmd.Dir = dir
-	cmd.Stdout = &out
+		zipFile, err := zipWriter.Create(filepath.ToSlash(name))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		_, err = io.Copy(zipFile, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	err := cmd.Run()
+	err := zipWriter.Close()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	return nil
+// RunScript runs a script in a directory, its output is written to `stdout` and `stderr`
+func RunScript(dir, script string, stdout, stderr io.Writer) error {
+
+	s := Script{Dir: dir, Command: "./" + script, Stdout: stdout, Stderr: stderr}
+
+	return s.Run(context.Background())
 }
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"archive/zip"
+	"bytes"
 	"math/rand"
 	"os"
 	"reflect"
@@ -177,6 +179,32 @@ func TestZip(t *testing.T) {
 	}
 }
 
+func TestZipFiles(t *testing.T) {
+	testDir := "/tmp/test-zip-files"
+	_ = os.MkdirAll(testDir+"/models", 0755)
+	defer os.RemoveAll(testDir)
+
+	_ = os.WriteFile(testDir+"/input.pdb", []byte("input"), 0644)
+	_ = os.WriteFile(testDir+"/models/model_1.pdb", []byte("model"), 0644)
+
+	got, err := ZipFiles(testDir, []string{"models/model_1.pdb", "gone.pdb"})
+	if err != nil {
+		t.Fatalf("ZipFiles() error = %v", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(got), int64(len(got)))
+	if err != nil {
+		t.Fatalf("ZipFiles() is not a zip: %v", err)
+	}
+	names := []string{}
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+	}
+	if want := []string{"models/model_1.pdb"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ZipFiles() files = %v, want %v", names, want)
+	}
+}
+
 func TestRunScript(t *testing.T) {
 	// Write a script that prints "hello world"
 	cmd := []byte("#!/bin/bash\necho \"hello\"")
@@ -211,6 +239,7 @@ echo "10"`
 				dir:    "./",
 				script: "script.sh",
 			},
+			want:    "hello\n",
 			wantErr: false,
 		},
 		{
@@ -224,11 +253,15 @@ echo "10"`
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := RunScript(tt.args.dir, tt.args.script)
+			var stdout, stderr bytes.Buffer
+			err := RunScript(tt.args.dir, tt.args.script, &stdout, &stderr)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RunScript() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if stdout.String() != tt.want {
+				t.Errorf("RunScript() stdout = %q, want %q", stdout.String(), tt.want)
+			}
 		})
 	}
 }